@@ -0,0 +1,74 @@
+package main
+
+import (
+	"math/rand"
+	"time"
+)
+
+type AILevel int
+
+const (
+	AIEasy AILevel = iota
+	AINormal
+	AIHard
+)
+
+var AILevelNames = []string{"简单", "普通", "困难"}
+
+// AIPlayer 电脑对手，只从本地诗库中选诗句
+type AIPlayer struct {
+	FavorOnly  bool           // 只会收藏的诗
+	PreferRare bool           // 优先用历史对局中很少有人说过的诗，难以预料
+	ForgetRate float64        // 每轮想不起来的概率
+	Usage      map[uint64]int // 每首诗在历史对局中被说出的次数，PreferRare时使用
+
+	rand *rand.Rand
+}
+
+func NewAI(level AILevel) *AIPlayer {
+	ai := &AIPlayer{rand: rand.New(rand.NewSource(time.Now().UnixNano()))}
+
+	switch level {
+	case AIEasy:
+		ai.FavorOnly = true
+		ai.ForgetRate = 0.3
+	case AINormal:
+		ai.ForgetRate = 0.1
+	case AIHard:
+		ai.PreferRare = true
+		ai.ForgetRate = 0
+	}
+
+	return ai
+}
+
+// Answer 选择一句还没有说过的诗句，想不起来时返回false
+func (ai *AIPlayer) Answer(g *Game) (string, bool) {
+	if ai.rand.Float64() < ai.ForgetRate {
+		return "", false
+	}
+
+	candidates := g.Candidates(ai.FavorOnly)
+	if ai.PreferRare && len(candidates) > 0 {
+		// 只在被说过次数最少的诗中选
+		least := -1
+		for _, c := range candidates {
+			if n := ai.Usage[c.Poem.ID]; least < 0 || n < least {
+				least = n
+			}
+		}
+		rare := make([]*GameLine, 0, len(candidates))
+		for _, c := range candidates {
+			if ai.Usage[c.Poem.ID] == least {
+				rare = append(rare, c)
+			}
+		}
+		candidates = rare
+	}
+
+	if len(candidates) == 0 {
+		return "", false
+	}
+
+	return candidates[ai.rand.Intn(len(candidates))].Segment.Text(), true
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"strings"
+)
+
+// bulkItem 解析出来的一首诗，添加前可以修改
+type bulkItem struct {
+	title, dynasty, author, content *widget.Entry
+	root                            fyne.CanvasObject
+}
+
+func (item *bulkItem) Validate() error {
+	fields := []struct {
+		name  string
+		entry *widget.Entry
+	}{{"标题", item.title}, {"朝代", item.dynasty}, {"作者", item.author}, {"内容", item.content}}
+
+	for _, field := range fields {
+		if len(strings.TrimSpace(field.entry.Text)) == 0 {
+			return fmt.Errorf("%s不能为空白", field.name)
+		}
+	}
+	return nil
+}
+
+func (item *bulkItem) Poem(no uint64) *Poem {
+	return NewPoem(no, strings.TrimSpace(item.title.Text), strings.TrimSpace(item.dynasty.Text),
+		strings.TrimSpace(item.author.Text), strings.TrimSpace(item.content.Text))
+}
+
+type BulkScreen struct {
+	root  fyne.CanvasObject
+	reset func()
+}
+
+func NewBulkScreen(poems *Poems, mgr *ScreenManager, win fyne.Window) *BulkScreen {
+	var items []*bulkItem
+
+	text := widget.NewMultiLineEntry()
+	text.SetPlaceHolder("粘贴多首诗，每首诗先写标题，下一行写 朝代·作者，然后是正文")
+	text.SetMinRowsVisible(6)
+	countLabel := widget.NewLabel("")
+	preview := container.NewVBox()
+
+	updatePreview := func() {
+		preview.Objects = nil
+		for _, item := range items {
+			preview.Add(item.root)
+		}
+		preview.Refresh()
+		countLabel.SetText(fmt.Sprintf("共 %d 首", len(items)))
+	}
+
+	newItem := func(poem *Poem) *bulkItem {
+		item := &bulkItem{
+			title:   widget.NewEntry(),
+			dynasty: widget.NewEntry(),
+			author:  widget.NewEntry(),
+			content: widget.NewMultiLineEntry(),
+		}
+		item.title.SetText(poem.Title)
+		item.dynasty.SetText(poem.Dynasty)
+		item.author.SetText(poem.Author)
+		item.content.SetText(poem.Content)
+		item.content.SetMinRowsVisible(4)
+
+		removeBtn := widget.NewButtonWithIcon("", theme.DeleteIcon(), func() {
+			for i, it := range items {
+				if it == item {
+					items = append(items[:i], items[i+1:]...)
+					break
+				}
+			}
+			updatePreview()
+		})
+		form := container.New(layout.NewFormLayout(),
+			widget.NewLabel("标题"), container.NewBorder(nil, nil, nil, removeBtn, item.title),
+			widget.NewLabel("朝代"), item.dynasty,
+			widget.NewLabel("作者"), item.author,
+			widget.NewLabel("内容"), item.content)
+		item.root = container.NewVBox(form, widget.NewSeparator())
+		return item
+	}
+
+	parseBtn := widget.NewButtonWithIcon("解析", theme.ViewRefreshIcon(), func() {
+		items = nil
+		for _, poem := range ParsePaste(text.Text) {
+			items = append(items, newItem(poem))
+		}
+		updatePreview()
+		if len(items) == 0 {
+			dialog.ShowError(errors.New("没有找到诗"), win)
+		}
+	})
+
+	addBtn := widget.NewButtonWithIcon("全部添加", theme.ContentAddIcon(), func() {
+		if len(items) == 0 {
+			return
+		}
+		for i, item := range items {
+			if err := item.Validate(); err != nil {
+				dialog.ShowError(fmt.Errorf("第%d首：%w", i+1, err), win)
+				return
+			}
+		}
+
+		for i, item := range items {
+			if err := poems.Add(item.Poem(poems.NextNo())); err != nil {
+				// 已经添加的不再保留在预览中
+				items = items[i:]
+				updatePreview()
+				dialog.ShowError(fmt.Errorf("添加了%d首，第%d首出错：%w", i, i+1, err), win)
+				return
+			}
+		}
+
+		dialog.ShowInformation("提示", fmt.Sprintf("添加了%d首", len(items)), win)
+		mgr.SwitchToWithCtx("entry", true)
+	})
+	returnBtn := widget.NewButtonWithIcon("返回", theme.NavigateBackIcon(), func() {
+		mgr.SwitchTo("entry")
+	})
+
+	top := container.NewVBox(text, container.NewBorder(nil, nil, nil, countLabel, parseBtn))
+	root := container.NewBorder(top, container.NewGridWithColumns(2, returnBtn, addBtn), nil, nil, container.NewVScroll(preview))
+
+	reset := func() {
+		items = nil
+		text.SetText("")
+		updatePreview()
+	}
+	reset()
+
+	return &BulkScreen{root: root, reset: reset}
+}
+
+func (s *BulkScreen) Show(interface{}) {
+	s.reset()
+	s.root.Show()
+}
+
+func (s *BulkScreen) Hide() {
+	s.root.Hide()
+}
+
+func (s *BulkScreen) RootObj() fyne.CanvasObject {
+	return s.root
+}
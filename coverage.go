@@ -0,0 +1,169 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// FlowerKeywords 飞花令常用的关键字
+var FlowerKeywords = []string{"花", "月", "春", "风", "山", "水", "云", "雨", "雪", "夜", "秋", "江", "天", "日", "酒", "人", "草", "柳"}
+
+// maxStatPos 统计位置时第maxStatPos个字以后的合在一起
+const maxStatPos = 8
+
+// weakLines 学习者会的不同诗句少于这个数时，认为这个关键字较弱
+const weakLines = 10
+
+// KeywordStat 一个关键字在诗库中的诗句数量
+type KeywordStat struct {
+	Keyword   string
+	Lines     int             // 含有关键字的诗句
+	Distinct  int             // 其中不同的诗句
+	Memorised int             // 学习者已背的诗中不同的诗句
+	Positions [maxStatPos]int // 关键字在诗句中第几个字，最后一项是第maxStatPos个字及以后
+}
+
+// Coverage 学习者会的诗句占诗库中不同诗句的比例
+func (s *KeywordStat) Coverage() float64 {
+	if s.Distinct == 0 {
+		return 0
+	}
+	return float64(s.Memorised) / float64(s.Distinct)
+}
+
+func (s *KeywordStat) Weak() bool {
+	return s.Memorised < weakLines
+}
+
+// keywordPositions 关键字在文字中出现的位置，从1开始，text和key都已经转换成简体
+func keywordPositions(text, key []rune) []int {
+	positions := make([]int, 0)
+	for i := 0; i+len(key) <= len(text); i++ {
+		if string(text[i:i+len(key)]) == string(key) {
+			positions = append(positions, i+1)
+		}
+	}
+	return positions
+}
+
+// lineKey 比较诗句是否相同时用，不区分简繁和标点
+func lineKey(seg *Segment) string {
+	return normalise(stripPunctuation(seg.Content))
+}
+
+// containsKeyword 诗句中是否有关键字，key已经转换成简体
+func containsKeyword(seg *Segment, key string) bool {
+	return strings.Contains(normalise(seg.Text()), key)
+}
+
+// KeywordStats 统计每个关键字在诗库中的诗句数量和位置，以及学习者已背的诗句数量，
+// memorised为学习者已背的诗的ID，见MemorisedPoems
+func (p *Poems) KeywordStats(keywords []string, memorised map[uint64]bool) []*KeywordStat {
+	stats := make([]*KeywordStat, len(keywords))
+	keys := make([][]rune, len(keywords))
+	distinct, known := make([]map[string]bool, len(keywords)), make([]map[string]bool, len(keywords))
+	for i, keyword := range keywords {
+		stats[i] = &KeywordStat{Keyword: keyword}
+		keys[i] = []rune(normalise(keyword))
+		distinct[i], known[i] = make(map[string]bool), make(map[string]bool)
+	}
+
+	// 每句只转换一次简繁
+	for _, poem := range p.list {
+		for _, seg := range poem.Segments {
+			text := []rune(normalise(stripPunctuation(seg.Text()))) // 位置与keywordAt一样不计标点和空白
+			for i, stat := range stats {
+				positions := keywordPositions(text, keys[i])
+				if len(positions) == 0 {
+					continue
+				}
+
+				stat.Lines++
+				key := lineKey(seg)
+				distinct[i][key] = true
+				if memorised[poem.ID] {
+					known[i][key] = true
+				}
+				for _, pos := range positions {
+					if pos > maxStatPos {
+						pos = maxStatPos
+					}
+					stat.Positions[pos-1]++
+				}
+			}
+		}
+	}
+
+	for i, stat := range stats {
+		stat.Distinct, stat.Memorised = len(distinct[i]), len(known[i])
+	}
+	return stats
+}
+
+// PoemGain 学会这首诗可以多会的诗句
+type PoemGain struct {
+	Poem  *Poem
+	Lines []*Segment
+}
+
+// SuggestPoems 学习者还没有背的诗中，含有关键字的新诗句最多的在前，一样多时短的诗在前
+func (p *Poems) SuggestPoems(keyword string, memorised map[uint64]bool, limit int) []*PoemGain {
+	keyword = normalise(keyword)
+	known := make(map[string]bool)
+	for _, poem := range p.list {
+		if !memorised[poem.ID] {
+			continue
+		}
+		for _, seg := range poem.Segments {
+			if containsKeyword(seg, keyword) {
+				known[lineKey(seg)] = true
+			}
+		}
+	}
+
+	gains := make([]*PoemGain, 0)
+	for _, poem := range p.list {
+		if memorised[poem.ID] {
+			continue
+		}
+
+		gain := &PoemGain{Poem: poem}
+		found := make(map[string]bool)
+		for _, seg := range poem.Segments {
+			key := lineKey(seg)
+			if containsKeyword(seg, keyword) && !known[key] && !found[key] {
+				found[key] = true
+				gain.Lines = append(gain.Lines, seg)
+			}
+		}
+		if len(gain.Lines) != 0 {
+			gains = append(gains, gain)
+		}
+	}
+
+	sort.SliceStable(gains, func(i, j int) bool {
+		if len(gains[i].Lines) != len(gains[j].Lines) {
+			return len(gains[i].Lines) > len(gains[j].Lines)
+		}
+		return len(gains[i].Poem.Segments) < len(gains[j].Poem.Segments)
+	})
+	if limit > 0 && len(gains) > limit {
+		gains = gains[:limit]
+	}
+	return gains
+}
+
+// ParseKeywords 用空格或逗号分开的关键字，去掉重复的
+func ParseKeywords(text string) []string {
+	found := make(map[string]bool)
+	keywords := make([]string, 0)
+	for _, keyword := range strings.FieldsFunc(text, func(r rune) bool {
+		return r == ',' || r == '，' || r == '、' || r == ' ' || r == '\t' || r == '\n'
+	}) {
+		if !found[keyword] {
+			found[keyword] = true
+			keywords = append(keywords, keyword)
+		}
+	}
+	return keywords
+}
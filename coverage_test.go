@@ -0,0 +1,86 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestKeywordStatsByProfile(t *testing.T) {
+	openTestDB(t)
+	poems := NewPoems()
+	list := []*Poem{
+		NewPoem(1, "静夜思", "唐代", "李白", "床前明月光，\n疑是地上霜。\n举头望明月，\n低头思故乡。"),
+		NewPoem(2, "月下独酌", "唐代", "李白", "花间一壶酒，\n独酌无相亲。\n举杯邀明月，\n对影成三人。"),
+		NewPoem(3, "山居秋暝", "唐代", "王维", "空山新雨后，\n天气晚来秋。\n明月松间照，\n清泉石上流。"),
+	}
+	for _, poem := range list {
+		if err := db.Create(poem).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	poems.setList(list)
+
+	current, other := &Profile{Name: "甲"}, &Profile{Name: "乙"}
+	for _, profile := range []*Profile{current, other} {
+		if err := db.Create(profile).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 甲背了静夜思，乙背了另外两首
+	states := []*ProfilePoem{
+		{ProfileID: current.ID, PoemID: list[0].ID, Memorised: true},
+		{ProfileID: other.ID, PoemID: list[0].ID, Favor: true},
+		{ProfileID: other.ID, PoemID: list[1].ID, Memorised: true},
+		{ProfileID: other.ID, PoemID: list[2].ID, Memorised: true},
+	}
+	for _, state := range states {
+		if err := db.Create(state).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := poems.SelectProfile(current.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		profile   *Profile
+		memorised int // 含“月”的已背诗句
+		suggested []string
+	}{
+		{current, 2, []string{"月下独酌", "山居秋暝"}},
+		{other, 2, []string{"静夜思"}},
+	}
+	for _, tt := range tests {
+		memorised, err := poems.MemorisedPoems(tt.profile)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		stat := poems.KeywordStats([]string{"月"}, memorised)[0]
+		if stat.Lines != 4 || stat.Distinct != 4 || stat.Memorised != tt.memorised {
+			t.Errorf("%s: lines %d distinct %d memorised %d, want 4 4 %d", tt.profile.Name, stat.Lines, stat.Distinct, stat.Memorised, tt.memorised)
+		}
+
+		gains := poems.SuggestPoems("月", memorised, 0)
+		titles := make([]string, len(gains))
+		for i, gain := range gains {
+			titles[i] = gain.Poem.Title
+		}
+		if strings.Join(titles, ",") != strings.Join(tt.suggested, ",") {
+			t.Errorf("%s: suggested %v, want %v", tt.profile.Name, titles, tt.suggested)
+		}
+	}
+
+	// 统计其他学习者不切换当前学习者
+	if poems.Profile().ID != current.ID || !list[0].Memorised || list[1].Memorised {
+		t.Error("current profile state changed")
+	}
+}
+
+func TestKeywordStatsPositions(t *testing.T) {
+	poems := newTestPoems(NewPoem(1, "夜雨寄北", "唐代", "李商隐", "君问归期未有期，\n巴山夜雨涨秋池。\n 何当共剪西窗烛，\n却话巴山夜雨时。"))
+	stat := poems.KeywordStats([]string{"何"}, nil)[0]
+	if stat.Positions[0] != 1 || stat.Positions[1] != 0 {
+		t.Errorf("positions of 何 = %v, want 1 at the first character", stat.Positions)
+	}
+}
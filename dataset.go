@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+// datasetPoem chinese-poetry数据集中的一首诗，不同的集子字段略有不同
+type datasetPoem struct {
+	Title      string   `json:"title"`
+	Rhythmic   string   `json:"rhythmic"` // 词牌名
+	Author     string   `json:"author"`
+	Paragraphs []string `json:"paragraphs"`
+	Content    []string `json:"content"` // 诗经、楚辞
+	Para       []string `json:"para"`    // 纳兰性德诗集
+}
+
+// datasetDynasties 按文件名判断朝代
+var datasetDynasties = []struct {
+	prefix  string
+	dynasty string
+	author  string
+}{
+	{"poet.tang", "唐代", ""},
+	{"poet.song", "宋代", ""},
+	{"ci.song", "宋代", ""},
+	{"shijing", "先秦", "佚名"},
+	{"chuci", "先秦", ""},
+	{"yuanqu", "元代", ""},
+	{"huajianji", "五代", ""},
+	{"nantang", "五代", ""},
+	{"caocao", "两汉", "曹操"},
+	{"nalanxingde", "清代", "纳兰性德"},
+	{"唐诗三百首", "唐代", ""},
+	{"宋词三百首", "宋代", ""},
+}
+
+func (d *datasetPoem) toPoem(dynasty, author string) *Poem {
+	title := d.Title
+	if len(d.Rhythmic) != 0 {
+		if len(title) == 0 || title == d.Rhythmic {
+			title = d.Rhythmic
+		} else {
+			title = d.Rhythmic + "·" + title
+		}
+	}
+	if len(d.Author) != 0 {
+		author = d.Author
+	}
+	if len(author) == 0 {
+		author = "佚名"
+	}
+
+	var paragraphs []string
+	switch {
+	case len(d.Paragraphs) != 0:
+		paragraphs = d.Paragraphs
+	case len(d.Content) != 0:
+		paragraphs = d.Content
+	default:
+		paragraphs = d.Para
+	}
+	return NewPoem(0, strings.TrimSpace(title), dynasty, strings.TrimSpace(author), breakLines(strings.Join(paragraphs, "")))
+}
+
+// ReadDataset 读取chinese-poetry数据集的一个文件，name为文件名，用来判断朝代
+func ReadDataset(name string, reader io.Reader) ([]*Poem, error) {
+	dynasty, author := "", ""
+	for _, d := range datasetDynasties {
+		if strings.HasPrefix(name, d.prefix) {
+			dynasty, author = d.dynasty, d.author
+			break
+		}
+	}
+	if len(dynasty) == 0 {
+		return nil, fmt.Errorf("不认识的数据集文件：%s", name)
+	}
+
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]*datasetPoem, 0)
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, decodeError(data, 0, 0, 0, err)
+	}
+
+	// 数据集中偶尔有缺少标题或内容的条目，跳过
+	list := make([]*Poem, 0, len(items))
+	for _, item := range items {
+		if poem := item.toPoem(dynasty, author); len(poem.Title) != 0 && len(poem.Segments) != 0 {
+			list = append(list, poem)
+		}
+	}
+	if len(list) == 0 {
+		return nil, errors.New("文件中没有诗")
+	}
+	return list, nil
+}
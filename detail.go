@@ -3,22 +3,113 @@ package main
 import (
 	"fmt"
 	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
 	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/data/binding"
 	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/layout"
 	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
+	"strings"
+	"unicode"
 )
 
+const pinyinPreference = "pinyin"
+
 type DetailContext struct {
 	poem   *Poem
 	search *Search
+	line   *Segment // 从逐句的搜索结果进入时突出显示的诗句
 }
 
 func NewDetailContext(poem *Poem, search *Search) *DetailContext {
 	return &DetailContext{poem: poem, search: search}
 }
 
+func NewLineDetailContext(poem *Poem, search *Search, line *Segment) *DetailContext {
+	return &DetailContext{poem: poem, search: search, line: line}
+}
+
+// newRuby 逐字显示，拼音标在字的上方，line中的字用主题色
+func newRuby(poem *Poem, line *Segment) fyne.CanvasObject {
+	box := container.NewVBox(widget.NewLabelWithStyle(display(poem.Title), fyne.TextAlignCenter, fyne.TextStyle{Bold: true}),
+		widget.NewLabelWithStyle(display(fmt.Sprintf("%s · %s", poem.Dynasty, poem.Author)), fyne.TextAlignCenter, fyne.TextStyle{}))
+
+	from, to := -1, -1
+	if line != nil {
+		if f, t, ok := poem.segmentRange(line); ok {
+			from, to = f, t
+		}
+	}
+
+	offset := 0
+	for _, text := range strings.Split(poem.Content, "\n") {
+		start := offset + len([]rune(text)) - len([]rune(strings.TrimLeftFunc(text, unicode.IsSpace)))
+		offset += len([]rune(text)) + 1
+		text = strings.TrimSpace(text)
+		if len(text) == 0 {
+			continue
+		}
+
+		runes, py := []rune(display(text)), Pinyin(normalise(text))
+		cells := make([]fyne.CanvasObject, len(runes))
+		for i, c := range runes {
+			color := theme.ForegroundColor()
+			if start+i >= from && start+i < to {
+				color = theme.PrimaryColor()
+			}
+			top := canvas.NewText(py[i], color)
+			top.TextSize = theme.CaptionTextSize()
+			top.Alignment = fyne.TextAlignCenter
+			char := canvas.NewText(string(c), color)
+			char.TextSize = theme.TextSize() * 1.5
+			char.Alignment = fyne.TextAlignCenter
+			cells[i] = container.NewVBox(top, char)
+		}
+		box.Add(container.NewGridWrap(fyne.NewSize(theme.TextSize()*3, theme.TextSize()*3.5), cells...))
+	}
+
+	return box
+}
+
+// markLine 突出显示的诗句在加粗的基础上用主题色
+func markLine(text *widget.RichText, marked string) {
+	if len(marked) == 0 {
+		return
+	}
+	marked = display(marked)
+	for _, seg := range text.Segments {
+		if t, ok := seg.(*widget.TextSegment); ok && t.Style.TextStyle.Bold && strings.TrimSpace(t.Text) == marked {
+			t.Style.ColorName = theme.ColorNamePrimary
+		}
+	}
+	text.Refresh()
+}
+
+// scrollToLine 按诗句所在的行估算在body中的位置，滚动到屏幕中间，没有诗句时回到顶部
+func scrollToLine(scroll *container.Scroll, body fyne.CanvasObject, poem *Poem, line *Segment) {
+	if line == nil {
+		scroll.ScrollToTop()
+		return
+	}
+	from, _, ok := poem.segmentRange(line)
+	if !ok {
+		scroll.ScrollToTop()
+		return
+	}
+
+	// 标题和作者各算一行
+	runes := []rune(poem.Content)
+	row := 2 + strings.Count(string(runes[:from]), "\n")
+	rows := 3 + strings.Count(poem.Content, "\n")
+	y := body.MinSize().Height*float32(row)/float32(rows) - scroll.Size().Height/2
+	if y < 0 {
+		y = 0
+	}
+	scroll.Offset = fyne.NewPos(0, y)
+	scroll.Refresh()
+}
+
 type DetailScreen struct {
 	root fyne.CanvasObject
 	ctx  binding.Untyped
@@ -27,7 +118,47 @@ type DetailScreen struct {
 func NewDetailScreen(poems *Poems, mgr *ScreenManager, win fyne.Window) *DetailScreen {
 	context := binding.NewUntyped()
 
-	text := widget.NewRichTextWithText("")
+	// 体裁、注释、译文等显示在诗的下面，两种显示方式各一份
+	newInfo := func() *widget.RichText {
+		info := widget.NewRichTextWithText("")
+		info.Wrapping = fyne.TextWrapWord
+		return info
+	}
+	text, textInfo := widget.NewRichTextWithText(""), newInfo()
+	textScroll := container.NewVScroll(container.NewVBox(text, textInfo))
+	ruby, rubyInfo := container.NewMax(), newInfo()
+	rubyScroll := container.NewVScroll(container.NewVBox(ruby, rubyInfo))
+
+	pinyinCheck := widget.NewCheck("拼音", nil)
+	pinyinCheck.SetChecked(fyne.CurrentApp().Preferences().Bool(pinyinPreference))
+	updateView := func() {
+		ctx, err := context.Get()
+		if err != nil || ctx == nil {
+			return
+		}
+		p := ctx.(*DetailContext)
+
+		if pinyinCheck.Checked {
+			ruby.Objects = []fyne.CanvasObject{newRuby(p.poem, p.line)}
+			ruby.Refresh()
+			rubyInfo.ParseMarkdown(p.poem.InfoMarkdown())
+			textScroll.Hide()
+			rubyScroll.Show()
+			scrollToLine(rubyScroll, ruby, p.poem, p.line)
+		} else {
+			c := NewPoemDetailTemplateContext(p.poem, p.search, p.line)
+			text.ParseMarkdown(c.Markdown())
+			markLine(text, c.Marked)
+			textInfo.ParseMarkdown(p.poem.InfoMarkdown())
+			rubyScroll.Hide()
+			textScroll.Show()
+			scrollToLine(textScroll, text, p.poem, p.line)
+		}
+	}
+	pinyinCheck.OnChanged = func(checked bool) {
+		fyne.CurrentApp().Preferences().SetBool(pinyinPreference, checked)
+		updateView()
+	}
 
 	returnBtn := widget.NewButtonWithIcon("返回", theme.NavigateBackIcon(), func() {
 		mgr.SwitchTo("entry")
@@ -59,6 +190,29 @@ func NewDetailScreen(poems *Poems, mgr *ScreenManager, win fyne.Window) *DetailS
 		}
 	})
 
+	var memorisedBtn *widget.Button
+	updateMemorisedBtn := func(memorised bool) {
+		if memorised {
+			memorisedBtn.SetText("已背")
+			memorisedBtn.SetIcon(theme.CheckButtonCheckedIcon())
+		} else {
+			memorisedBtn.SetText("未背")
+			memorisedBtn.SetIcon(theme.CheckButtonIcon())
+		}
+	}
+	memorisedBtn = widget.NewButtonWithIcon("未背", theme.CheckButtonIcon(), func() {
+		if ctx, err := context.Get(); err != nil || ctx == nil {
+			return
+		} else {
+			p := ctx.(*DetailContext).poem
+			if err := poems.ToggleMemorised(p); err != nil {
+				dialog.ShowError(err, win)
+			} else {
+				updateMemorisedBtn(p.Memorised)
+			}
+		}
+	})
+
 	context.AddListener(binding.NewDataListener(func() {
 		ctx, err := context.Get()
 		if err != nil || ctx == nil {
@@ -66,11 +220,19 @@ func NewDetailScreen(poems *Poems, mgr *ScreenManager, win fyne.Window) *DetailS
 		}
 		p := ctx.(*DetailContext)
 
-		text.ParseMarkdown(p.poem.DetailMarkdown(p.search))
+		updateView()
+		updateMemorisedBtn(p.poem.Memorised)
+		if err := poems.MarkViewed(p.poem); err != nil {
+			dialog.ShowError(err, win)
+		}
 	}))
 
+	root := container.NewBorder(container.NewHBox(layout.NewSpacer(), pinyinCheck),
+		container.NewGridWithColumns(4, returnBtn, memorisedBtn, editBtn, delBtn), nil, nil,
+		container.NewMax(textScroll, rubyScroll))
+
 	return &DetailScreen{
-		root: container.NewBorder(nil, container.NewGridWithColumns(3, returnBtn, editBtn, delBtn), nil, nil, container.NewScroll(text)),
+		root: root,
 		ctx:  context,
 	}
 }
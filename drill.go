@@ -0,0 +1,155 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+type DrillScreen struct {
+	root  fyne.CanvasObject
+	reset func()
+}
+
+func NewDrillScreen(poems *Poems, mgr *ScreenManager, win fyne.Window) *DrillScreen {
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	var questions []*DrillQuestion
+	streak, best := 0, 0
+
+	ruleEntry := widget.NewEntry()
+	ruleEntry.SetPlaceHolder("出题范围，例如：d唐 或 a李白")
+	favorCheck := widget.NewCheck("仅收藏", nil)
+
+	streakLabel := widget.NewLabel("")
+	prompt := widget.NewLabel("")
+	prompt.Wrapping = fyne.TextWrapWord
+	shown := widget.NewRichTextWithText("")
+	result := widget.NewLabel("")
+	result.Wrapping = fyne.TextWrapWord
+	answer := widget.NewEntry()
+	answer.SetPlaceHolder("请输入诗句")
+
+	updateStreak := func() {
+		streakLabel.SetText(fmt.Sprintf("连续答对 %d 句，最好成绩 %d 句", streak, best))
+	}
+
+	ask := func() {
+		q := questions[0]
+		if q.IsNext() {
+			prompt.SetText("下一句是？")
+		} else {
+			prompt.SetText("上一句是？")
+		}
+		shown.ParseMarkdown(fmt.Sprintf("## %s", display(q.Shown().Content)))
+		answer.SetText("")
+		answer.Enable()
+	}
+
+	next := func() {
+		if len(questions) == 0 {
+			questions = NewDrill(poems.Filter(NewSearch(ruleEntry.Text, favorCheck.Checked)), r)
+		}
+		if len(questions) == 0 {
+			dialog.ShowError(errors.New("没有符合条件的诗"), win)
+			return
+		}
+		result.SetText("")
+		ask()
+	}
+
+	submit := func() {
+		if len(questions) == 0 || answer.Disabled() {
+			return
+		}
+
+		q := questions[0]
+		correct := q.Check(answer.Text)
+		if correct {
+			streak++
+			if streak > best {
+				best = streak
+			}
+			result.SetText(fmt.Sprintf("✓ 正确 —— %s", q.Poem.Abstract()))
+		} else {
+			streak = 0
+			result.SetText(fmt.Sprintf("✗ 应为：%s —— %s", display(q.Expected().Content), q.Poem.Abstract()))
+		}
+		updateStreak()
+		answer.Disable()
+		questions = questions[1:]
+
+		if err := poems.RecordDrill(q, correct); err != nil {
+			dialog.ShowError(err, win)
+		}
+	}
+	answer.OnSubmitted = func(string) {
+		submit()
+	}
+
+	statsBtn := widget.NewButtonWithIcon("统计", theme.InfoIcon(), func() {
+		stats, err := poems.DrillStats()
+		if err != nil {
+			dialog.ShowError(err, win)
+			return
+		}
+
+		var b strings.Builder
+		for _, stat := range stats {
+			if poem := poems.FindByID(stat.PoemID); poem != nil {
+				fmt.Fprintf(&b, "- %s：错%d次 / 共%d次\n", poem.Abstract(), stat.Errors, stat.Attempts)
+			}
+		}
+		if b.Len() == 0 {
+			b.WriteString("还没有答错过")
+		}
+
+		text := widget.NewRichTextFromMarkdown(b.String())
+		text.Wrapping = fyne.TextWrapWord
+		scroll := container.NewVScroll(text)
+		scroll.SetMinSize(fyne.NewSize(400, 400))
+		dialog.ShowCustom("易错的诗", "关闭", scroll, win)
+	})
+	submitBtn := widget.NewButtonWithIcon("提交", theme.ConfirmIcon(), submit)
+	nextBtn := widget.NewButtonWithIcon("下一题", theme.NavigateNextIcon(), next)
+	returnBtn := widget.NewButtonWithIcon("返回", theme.NavigateBackIcon(), func() {
+		mgr.SwitchTo("entry")
+	})
+
+	top := container.NewVBox(container.NewBorder(nil, nil, favorCheck, nil, ruleEntry), streakLabel)
+	center := container.NewVBox(prompt, shown, container.NewBorder(nil, nil, nil, submitBtn, answer), result)
+	root := container.NewBorder(top, container.NewGridWithColumns(3, returnBtn, statsBtn, nextBtn), nil, nil, container.NewVScroll(center))
+
+	reset := func() {
+		questions = nil
+		streak = 0
+		updateStreak()
+		prompt.SetText("点击“下一题”开始")
+		shown.ParseMarkdown("")
+		result.SetText("")
+		answer.SetText("")
+		answer.Disable()
+	}
+	reset()
+
+	return &DrillScreen{root: root, reset: reset}
+}
+
+func (s *DrillScreen) Show(interface{}) {
+	s.reset()
+	s.root.Show()
+}
+
+func (s *DrillScreen) Hide() {
+	s.root.Hide()
+}
+
+func (s *DrillScreen) RootObj() fyne.CanvasObject {
+	return s.root
+}
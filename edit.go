@@ -71,10 +71,41 @@ func NewEditScreen(poems *Poems, mgr *ScreenManager, win fyne.Window) *EditScree
 		return nil
 	}
 
-	editable := container.NewBorder(container.New(layout.NewFormLayout(), widget.NewLabel("序号"), no,
+	genre := widget.NewSelectEntry(Genres)
+	tags := widget.NewEntry()
+	tags.SetPlaceHolder("用空格或逗号分开，例如 课本 送别")
+	source := widget.NewEntry()
+	source.SetPlaceHolder("例如 唐诗三百首、三年级上册")
+	annotations := widget.NewMultiLineEntry()
+	annotations.SetPlaceHolder("每行一条，例如 举头：抬头")
+	translation := widget.NewMultiLineEntry()
+	translation.Wrapping = fyne.TextWrapWord
+	note := widget.NewMultiLineEntry()
+	note.Wrapping = fyne.TextWrapWord
+
+	poemTab := container.NewTabItem("正文", container.NewBorder(container.New(layout.NewFormLayout(), widget.NewLabel("序号"), no,
 		widget.NewLabel("标题"), title,
 		widget.NewLabel("朝代"), dynasty,
-		widget.NewLabel("作者"), author), nil, nil, nil, content)
+		widget.NewLabel("作者"), author), nil, nil, nil, content))
+	infoTab := container.NewTabItem("注释译文", container.NewBorder(container.New(layout.NewFormLayout(),
+		widget.NewLabel("体裁"), genre,
+		widget.NewLabel("标签"), tags,
+		widget.NewLabel("出处"), source), nil, nil, nil,
+		container.NewGridWithRows(3,
+			container.NewBorder(widget.NewLabel("注释"), nil, nil, nil, annotations),
+			container.NewBorder(widget.NewLabel("译文"), nil, nil, nil, translation),
+			container.NewBorder(widget.NewLabel("备注"), nil, nil, nil, note))))
+	editable := container.NewAppTabs(poemTab, infoTab)
+
+	// setInfo 编辑界面中的附加信息
+	setInfo := func(poem *Poem) {
+		poem.Genre = strings.TrimSpace(genre.Text)
+		poem.Tags = ParseTags(tags.Text)
+		poem.Source = strings.TrimSpace(source.Text)
+		poem.Annotations = ParseAnnotations(annotations.Text)
+		poem.Translation = strings.TrimSpace(translation.Text)
+		poem.Note = strings.TrimSpace(note.Text)
+	}
 
 	saveBtn := widget.NewButtonWithIcon("保存", theme.DocumentSaveIcon(), func() {
 		if ctx, err := context.Get(); err != nil || ctx == nil {
@@ -116,6 +147,7 @@ func NewEditScreen(poems *Poems, mgr *ScreenManager, win fyne.Window) *EditScree
 				}
 				aNo, _ := strconv.ParseUint(no_, 10, 64)
 				aPoem := NewPoem(aNo, title_, dynasty_, author_, content_)
+				setInfo(aPoem)
 				if err := poems.Modify(p, aPoem); err != nil {
 					dialog.ShowError(err, win)
 					return
@@ -130,6 +162,7 @@ func NewEditScreen(poems *Poems, mgr *ScreenManager, win fyne.Window) *EditScree
 				}
 
 				aPoem := NewPoem(aNo, title_, dynasty_, author_, content_)
+				setInfo(aPoem)
 				if err := poems.Add(aPoem); err != nil {
 					dialog.ShowError(err, win)
 					return
@@ -151,6 +184,10 @@ func NewEditScreen(poems *Poems, mgr *ScreenManager, win fyne.Window) *EditScree
 		}
 	})
 
+	bulkBtn := widget.NewButtonWithIcon("批量添加", theme.ContentPasteIcon(), func() {
+		mgr.SwitchTo("bulk")
+	})
+
 	context.AddListener(binding.NewDataListener(func() {
 		ctx, err := context.Get()
 		if err != nil || ctx == nil {
@@ -158,18 +195,33 @@ func NewEditScreen(poems *Poems, mgr *ScreenManager, win fyne.Window) *EditScree
 		}
 		p := ctx.(*EditContext).poem
 		if p != nil {
+			bulkBtn.Disable()
 			no.Text = fmt.Sprintf("%d", p.No)
 			title.Text = p.Title
 			author.Text = p.Author
 			dynasty.Text = p.Dynasty
 			content.Text = p.Content
+			genre.SetText(p.Genre)
+			tags.SetText(strings.Join(p.Tags, " "))
+			source.SetText(p.Source)
+			annotations.SetText(FormatAnnotations(p.Annotations))
+			translation.SetText(p.Translation)
+			note.SetText(p.Note)
 		} else {
+			bulkBtn.Enable()
 			no.Text = ""
 			title.Text = ""
 			author.Text = ""
 			dynasty.Text = ""
 			content.Text = ""
+			genre.SetText("")
+			tags.SetText("")
+			source.SetText("")
+			annotations.SetText("")
+			translation.SetText("")
+			note.SetText("")
 		}
+		editable.SelectIndex(0)
 
 		no.Refresh()
 		title.Refresh()
@@ -179,7 +231,7 @@ func NewEditScreen(poems *Poems, mgr *ScreenManager, win fyne.Window) *EditScree
 	}))
 
 	return &EditScreen{
-		root: container.NewBorder(nil, container.NewGridWithColumns(2, cancelBtn, saveBtn), nil, nil, editable),
+		root: container.NewBorder(nil, container.NewGridWithColumns(3, cancelBtn, bulkBtn, saveBtn), nil, nil, editable),
 		ctx:  context,
 	}
 }
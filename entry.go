@@ -2,6 +2,7 @@ package main
 
 import (
 	"errors"
+	"fmt"
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/data/binding"
@@ -9,6 +10,7 @@ import (
 	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
 	"strconv"
+	"strings"
 )
 
 type EntryScreen struct {
@@ -22,21 +24,69 @@ func NewEntryScreen(poems *Poems, mgr *ScreenManager, win fyne.Window) *EntryScr
 
 	rule := binding.NewString()
 	favorOnly := binding.NewBool()
+	memorisedOnly := binding.NewBool()
+	fuzzy := binding.NewBool()
+	searchError := widget.NewLabel("")
+	searchError.Wrapping = fyne.TextWrapWord
+	searchError.Hide()
 	updateSearch := func() {
 		rule_, _ := rule.Get()
 		favorOnly_, _ := favorOnly.Get()
-		_ = search.Set(NewSearch(rule_, favorOnly_))
+		memorisedOnly_, _ := memorisedOnly.Get()
+		fuzzy_, _ := fuzzy.Get()
+		// 有语法错误时仍按能解析的部分搜索
+		s, err := ParseSearch(rule_, favorOnly_)
+		if err != nil {
+			searchError.SetText(fmt.Sprintf("搜索规则有误：%s", err))
+			searchError.Show()
+		} else {
+			searchError.Hide()
+		}
+		s.MemorisedOnly = memorisedOnly_
+		s.Fuzzy = fuzzy_
+		_ = search.Set(s)
 	}
 	rule.AddListener(binding.NewDataListener(updateSearch))
 	favorOnly.AddListener(binding.NewDataListener(updateSearch))
+	memorisedOnly.AddListener(binding.NewDataListener(updateSearch))
+	fuzzy.AddListener(binding.NewDataListener(updateSearch))
 
 	favorCheck := widget.NewCheckWithData("仅收藏", favorOnly)
+	memorisedCheck := widget.NewCheckWithData("仅已背", memorisedOnly)
+	fuzzyCheck := widget.NewCheckWithData("模糊", fuzzy)
+	lineMode := binding.NewBool()
+	lineCheck := widget.NewCheckWithData("逐句", lineMode)
+	countLabel := widget.NewLabel("")
 	ruleEntry := widget.NewEntryWithData(rule)
-	ruleEntry.SetPlaceHolder("请输入要搜索的词")
+	ruleEntry.SetPlaceHolder("请输入要搜索的词，花@2 第2个字是花，月|花 含月或花，-月 不含月，author:李白")
 	clearRuleBtn := widget.NewButtonWithIcon("清空", theme.ContentClearIcon(), func() {
 		ruleEntry.SetText("")
 	})
-	searchBar := container.NewBorder(nil, nil, favorCheck, clearRuleBtn, ruleEntry)
+	// 没有搜到时列出相近的搜索规则
+	suggestionBox := container.NewHBox()
+	suggestionBar := container.NewHScroll(suggestionBox)
+	suggestionBar.Hide()
+	showSuggestions := func(suggestions []string) {
+		suggestionBox.Objects = []fyne.CanvasObject{widget.NewLabel("你是不是要找：")}
+		for _, suggestion := range suggestions {
+			suggestion := suggestion
+			suggestionBox.Add(widget.NewButton(suggestion, func() {
+				ruleEntry.SetText(suggestion)
+			}))
+		}
+		suggestionBox.Refresh()
+		if len(suggestions) == 0 {
+			suggestionBar.Hide()
+		} else {
+			suggestionBar.Show()
+		}
+	}
+
+	sortSelect := widget.NewSelect(SortOrderNames, nil)
+	sortSelect.SetSelectedIndex(fyne.CurrentApp().Preferences().Int(sortPreference))
+
+	searchBar := container.NewBorder(nil, container.NewVBox(searchError, suggestionBar, countLabel),
+		container.NewHBox(favorCheck, memorisedCheck, fuzzyCheck, lineCheck), container.NewHBox(sortSelect, clearRuleBtn), ruleEntry)
 
 	poemData := binding.NewUntypedList()
 	poemBrowserList := widget.NewListWithData(poemData,
@@ -118,29 +168,67 @@ func NewEntryScreen(poems *Poems, mgr *ScreenManager, win fyne.Window) *EntryScr
 				updateToggleFavorBtn(p.Favor)
 
 				toggleFavorBtn.OnTapped = func() {
-					p.Favor = !p.Favor
-					updateToggleFavorBtn(p.Favor)
+					if err := poems.ToggleFavor(p); err != nil {
+						dialog.ShowError(err, win)
+					} else {
+						updateToggleFavorBtn(p.Favor)
+					}
 				}
 			}))
 		})
 
-	showSearchList := func(b bool) {
-		if b {
-			poemSearchList.Refresh()
-			poemSearchList.Show()
-			poemBrowserList.Hide()
-		} else {
-			poemSearchList.Hide()
-			poemBrowserList.Refresh()
-			poemBrowserList.Show()
+	// 逐句显示搜索结果，每行一句
+	lineData := binding.NewUntypedList()
+	poemLineList := widget.NewListWithData(lineData,
+		func() fyne.CanvasObject {
+			preview := widget.NewRichTextWithText("\n")
+			showDetailBtn := widget.NewButtonWithIcon("", theme.NavigateNextIcon(), func() {
+			})
+			return container.NewBorder(nil, nil, showDetailBtn, nil, preview)
+		},
+		func(item binding.DataItem, o fyne.CanvasObject) {
+			item.AddListener(binding.NewDataListener(func() {
+				i, _ := item.(binding.Untyped).Get()
+				m := i.(*LineMatch)
+
+				objs := o.(*fyne.Container).Objects
+				preview, showDetailBtn := objs[0].(*widget.RichText), objs[1].(*widget.Button)
+
+				s, _ := search.Get()
+				search_ := s.(*Search)
+				preview.ParseMarkdown(m.Markdown(search_))
+
+				showDetailBtn.OnTapped = func() {
+					mgr.SwitchToWithCtx("detail", NewLineDetailContext(m.Poem, search_, m.Segment))
+				}
+			}))
+		})
+	poemLineList.OnSelected = func(id widget.ListItemID) {
+		poemLineList.Unselect(id)
+		if i, err := lineData.GetValue(id); err == nil {
+			s, _ := search.Get()
+			m := i.(*LineMatch)
+			mgr.SwitchToWithCtx("detail", NewLineDetailContext(m.Poem, s.(*Search), m.Segment))
+		}
+	}
+
+	showList := func(list *widget.List) {
+		for _, l := range []*widget.List{poemBrowserList, poemSearchList, poemLineList} {
+			if l == list {
+				l.Refresh()
+				l.Show()
+			} else {
+				l.Hide()
+			}
 		}
 	}
-	showSearchList(false)
+	showList(poemBrowserList)
 
 	updateList := func() {
 		s, _ := search.Get()
 		search_ := s.(*Search)
 		filteredPoems := poems.Filter(search_)
+		poems.Sort(filteredPoems, search_, SortOrder(sortSelect.SelectedIndex()))
 
 		filtered := make([]interface{}, len(filteredPoems))
 		for i := range filtered {
@@ -148,7 +236,38 @@ func NewEntryScreen(poems *Poems, mgr *ScreenManager, win fyne.Window) *EntryScr
 		}
 
 		_ = poemData.Set(filtered)
-		showSearchList(search_.HasKeyword())
+
+		lineMode_, _ := lineMode.Get()
+		switch {
+		case search_.HasKeyword() && lineMode_:
+			lines := MatchedLines(filteredPoems, search_)
+			items := make([]interface{}, len(lines))
+			for i := range items {
+				items[i] = lines[i]
+			}
+			_ = lineData.Set(items)
+			countLabel.SetText(fmt.Sprintf("共 %d 句，其中不同的 %d 句", len(lines), CountDistinctLines(lines)))
+			showList(poemLineList)
+		case search_.HasKeyword():
+			countLabel.SetText(fmt.Sprintf("共 %d 首", len(filteredPoems)))
+			showList(poemSearchList)
+		default:
+			countLabel.SetText(fmt.Sprintf("共 %d 首", len(filteredPoems)))
+			showList(poemBrowserList)
+		}
+
+		var suggestions []string
+		if len(filteredPoems) == 0 && len(search_.Content) != 0 {
+			rule_, _ := rule.Get()
+			suggestions = poems.Suggest(rule_, search_, 5)
+		}
+		showSuggestions(suggestions)
+	}
+
+	lineMode.AddListener(binding.NewDataListener(updateList))
+	sortSelect.OnChanged = func(string) {
+		fyne.CurrentApp().Preferences().SetInt(sortPreference, sortSelect.SelectedIndex())
+		updateList()
 	}
 
 	gotoBtn := widget.NewButtonWithIcon("跳转", theme.SearchIcon(), func() {
@@ -181,40 +300,117 @@ func NewEntryScreen(poems *Poems, mgr *ScreenManager, win fyne.Window) *EntryScr
 		}, win)
 	})
 	exportBtn := widget.NewButtonWithIcon("导出", theme.DocumentSaveIcon(), func() {
-		dialog.ShowFileSave(func(writer fyne.URIWriteCloser, err error) {
-			if err != nil || writer == nil {
+		s, _ := search.Get()
+		showExportDialog(poems, s.(*Search), win)
+	})
+	importBtn := widget.NewButtonWithIcon("导入", theme.FolderOpenIcon(), func() {
+		showImportDialog(poems, win, updateList)
+	})
+	addBtn := widget.NewButtonWithIcon("添加", theme.ContentAddIcon(), func() {
+		mgr.SwitchTo("edit")
+	})
+
+	var practiceBtn *widget.Button
+	practiceMenu := fyne.NewMenu("",
+		fyne.NewMenuItem("飞花令", func() { mgr.SwitchTo("game") }),
+		fyne.NewMenuItem("今日复习", func() { mgr.SwitchTo("review") }),
+		fyne.NewMenuItem("默写", func() { mgr.SwitchTo("quiz") }),
+		fyne.NewMenuItem("接下句", func() { mgr.SwitchTo("drill") }),
+		fyne.NewMenuItem("关键字统计", func() { mgr.SwitchTo("stats") }),
+	)
+	practiceBtn = widget.NewButtonWithIcon("练习", theme.MediaPlayIcon(), func() {
+		pos := fyne.CurrentApp().Driver().AbsolutePositionForObject(practiceBtn)
+		widget.ShowPopUpMenuAtPosition(practiceMenu, win.Canvas(), pos)
+	})
+
+	profileSelect := widget.NewSelect(nil, nil)
+	selectProfile := func(name string) {
+		profiles, err := poems.Profiles()
+		if err != nil {
+			dialog.ShowError(err, win)
+			return
+		}
+
+		for _, profile := range profiles {
+			if profile.Name == name && profile.ID != poems.Profile().ID {
+				if err := poems.SelectProfile(profile.ID); err != nil {
+					dialog.ShowError(err, win)
+					return
+				}
+				fyne.CurrentApp().Preferences().SetInt(profilePreference, int(profile.ID))
+				updateList()
+				break
+			}
+		}
+	}
+	updateProfiles := func() {
+		profiles, err := poems.Profiles()
+		if err != nil {
+			dialog.ShowError(err, win)
+			return
+		}
+
+		names := make([]string, 0, len(profiles))
+		for _, profile := range profiles {
+			names = append(names, profile.Name)
+		}
+
+		profileSelect.OnChanged = nil
+		profileSelect.Options = names
+		profileSelect.SetSelected(poems.Profile().Name)
+		profileSelect.OnChanged = selectProfile
+	}
+	updateProfiles()
+
+	addProfileBtn := widget.NewButtonWithIcon("", theme.ContentAddIcon(), func() {
+		nameEntry := widget.NewEntry()
+		nameEntry.Validator = func(s string) error {
+			if len(strings.TrimSpace(s)) == 0 {
+				return errors.New("名字不能为空白")
+			}
+			return nil
+		}
+		dialog.ShowForm("添加学习者", "添加", "取消", []*widget.FormItem{widget.NewFormItem("名字", nameEntry)}, func(b bool) {
+			if !b {
 				return
 			}
 
-			if err := poems.Export(writer); err != nil {
+			profile, err := poems.AddProfile(strings.TrimSpace(nameEntry.Text))
+			if err != nil {
 				dialog.ShowError(err, win)
-			} else {
-				dialog.ShowInformation(" 提示", "导出成功", win)
+				return
 			}
+			updateProfiles()
+			profileSelect.SetSelected(profile.Name)
 		}, win)
 	})
-	importBtn := widget.NewButtonWithIcon("导入", theme.FolderOpenIcon(), func() {
-		dialog.ShowFileOpen(func(reader fyne.URIReadCloser, err error) {
-			if err != nil || reader == nil {
+	removeProfileBtn := widget.NewButtonWithIcon("", theme.DeleteIcon(), func() {
+		dialog.ShowConfirm("警告", fmt.Sprintf("删除学习者 %s 及其收藏？", poems.Profile().Name), func(b bool) {
+			if !b {
 				return
 			}
 
-			defer func() {
-				err = reader.Close()
-			}()
-			if err := poems.Import(reader); err != nil {
+			if err := poems.RemoveProfile(); err != nil {
 				dialog.ShowError(err, win)
+				return
 			}
+			fyne.CurrentApp().Preferences().SetInt(profilePreference, int(poems.Profile().ID))
+			updateProfiles()
 			updateList()
 		}, win)
 	})
-	addBtn := widget.NewButtonWithIcon("添加", theme.ContentAddIcon(), func() {
-		mgr.SwitchTo("edit")
-	})
+	scriptSelect := widget.NewSelect(ScriptNames, nil)
+	scriptSelect.SetSelectedIndex(int(displayScript))
+	scriptSelect.OnChanged = func(string) {
+		SetDisplayScript(Script(scriptSelect.SelectedIndex()))
+		fyne.CurrentApp().Preferences().SetInt(scriptPreference, scriptSelect.SelectedIndex())
+		updateList()
+	}
+	profileBar := container.NewBorder(nil, nil, widget.NewLabel("学习者"), container.NewHBox(addProfileBtn, removeProfileBtn, scriptSelect), profileSelect)
 
 	search.AddListener(binding.NewDataListener(updateList))
 
-	root := container.NewBorder(searchBar, container.NewGridWithColumns(4, gotoBtn, exportBtn, importBtn, addBtn), nil, nil, container.NewMax(poemBrowserList, poemSearchList))
+	root := container.NewBorder(container.NewVBox(profileBar, searchBar), container.NewGridWithColumns(5, gotoBtn, exportBtn, importBtn, addBtn, practiceBtn), nil, nil, container.NewMax(poemBrowserList, poemSearchList, poemLineList))
 
 	return &EntryScreen{root: root, update: updateList}
 }
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+	"time"
+)
+
+// EpubGroup 目录按什么分组
+type EpubGroup int
+
+const (
+	GroupByDynasty EpubGroup = iota
+	GroupByAuthor
+)
+
+var EpubGroupNames = []string{"按朝代", "按作者"}
+
+type epubChapter struct {
+	Order int
+	ID    string
+	File  string
+	Poem  *Poem
+	Lines []template.HTML
+}
+
+type epubSection struct {
+	Name     string
+	Chapters []*epubChapter
+}
+
+type epubBook struct {
+	ID       string
+	Title    string
+	Author   string
+	Modified string
+	Sections []*epubSection
+	Chapters []*epubChapter
+}
+
+var epubContainer = `<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>`
+
+var epubOpfTpl = template.Must(template.New("opf").Parse(`<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="book-id" xml:lang="zh-CN">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:identifier id="book-id">{{.ID}}</dc:identifier>
+    <dc:title>{{.Title}}</dc:title>
+    <dc:creator>{{.Author}}</dc:creator>
+    <dc:language>zh-CN</dc:language>
+    <meta property="dcterms:modified">{{.Modified}}</meta>
+    <meta name="cover" content="cover-image"/>
+  </metadata>
+  <manifest>
+    <item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+    <item id="ncx" href="toc.ncx" media-type="application/x-dtbncx+xml"/>
+    <item id="style" href="style.css" media-type="text/css"/>
+    <item id="cover-image" href="cover.svg" media-type="image/svg+xml" properties="cover-image"/>
+    <item id="cover" href="cover.xhtml" media-type="application/xhtml+xml" properties="svg"/>
+{{- range .Chapters}}
+    <item id="{{.ID}}" href="{{.File}}" media-type="application/xhtml+xml"/>
+{{- end}}
+  </manifest>
+  <spine toc="ncx">
+    <itemref idref="cover"/>
+    <itemref idref="nav"/>
+{{- range .Chapters}}
+    <itemref idref="{{.ID}}"/>
+{{- end}}
+  </spine>
+</package>`))
+
+var epubNavTpl = template.Must(template.New("nav").Parse(`<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" xml:lang="zh-CN">
+<head><title>目录</title><link rel="stylesheet" type="text/css" href="style.css"/></head>
+<body>
+  <nav epub:type="toc" id="toc">
+    <h1>目录</h1>
+    <ol>
+{{- range .Sections}}
+      <li><span>{{.Name}}</span>
+        <ol>
+{{- range .Chapters}}
+          <li><a href="{{.File}}">{{.Poem.Title}}</a></li>
+{{- end}}
+        </ol>
+      </li>
+{{- end}}
+    </ol>
+  </nav>
+</body>
+</html>`))
+
+var epubNcxTpl = template.Must(template.New("ncx").Parse(`<ncx xmlns="http://www.daisy.org/z3986/2005/ncx/" version="2005-1">
+  <head><meta name="dtb:uid" content="{{.ID}}"/></head>
+  <docTitle><text>{{.Title}}</text></docTitle>
+  <navMap>
+{{- range $c := .Chapters}}
+    <navPoint id="nav-{{$c.ID}}" playOrder="{{$c.Order}}"><navLabel><text>{{$c.Poem.Title}}</text></navLabel><content src="{{$c.File}}"/></navPoint>
+{{- end}}
+  </navMap>
+</ncx>`))
+
+var epubCoverSvgTpl = template.Must(template.New("cover-svg").Parse(`<svg xmlns="http://www.w3.org/2000/svg" width="600" height="800" viewBox="0 0 600 800">
+  <rect width="600" height="800" fill="#f5efe0"/>
+  <rect x="40" y="40" width="520" height="720" fill="none" stroke="#8b4513" stroke-width="4"/>
+  <text x="300" y="340" font-size="56" text-anchor="middle" fill="#5a2d0c">{{.Title}}</text>
+  <text x="300" y="440" font-size="28" text-anchor="middle" fill="#5a2d0c">共{{len .Chapters}}首</text>
+</svg>`))
+
+var epubCoverTpl = template.Must(template.New("cover").Parse(`<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xml:lang="zh-CN">
+<head><title>{{.Title}}</title><link rel="stylesheet" type="text/css" href="style.css"/></head>
+<body class="cover">
+  <svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" width="100%" height="100%" viewBox="0 0 600 800">
+    <image width="600" height="800" xlink:href="cover.svg"/>
+  </svg>
+</body>
+</html>`))
+
+var epubChapterTpl = template.Must(template.New("chapter").Parse(`<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xml:lang="zh-CN">
+<head><title>{{.Poem.Title}}</title><link rel="stylesheet" type="text/css" href="style.css"/></head>
+<body>
+  <h1>{{.Poem.Title}}</h1>
+  <p class="author">{{.Poem.Dynasty}} {{.Poem.Author}}</p>
+{{- range .Lines}}
+  <p class="line">{{.}}</p>
+{{- end}}
+</body>
+</html>`))
+
+const epubStyle = `body { font-family: serif; }
+h1 { text-align: center; }
+.author { text-align: center; color: #666; }
+.line { text-align: center; font-size: 1.2em; line-height: 2.2em; margin: 0; }
+rt { font-size: 0.5em; }
+.cover { margin: 0; padding: 0; text-align: center; }
+nav ol { list-style: none; }`
+
+// epubLine 一行诗，需要时每个字加上拼音
+func epubLine(line string, ruby bool) template.HTML {
+	line = display(strings.TrimSpace(line))
+	if !ruby {
+		return template.HTML(template.HTMLEscapeString(line))
+	}
+
+	var b strings.Builder
+	py := Pinyin(normalise(line))
+	for i, c := range []rune(line) {
+		if len(py[i]) == 0 {
+			b.WriteString(template.HTMLEscapeString(string(c)))
+			continue
+		}
+		fmt.Fprintf(&b, "<ruby>%s<rt>%s</rt></ruby>", template.HTMLEscapeString(string(c)), template.HTMLEscapeString(py[i]))
+	}
+	return template.HTML(b.String())
+}
+
+func newEpubBook(title string, list []*Poem, group EpubGroup, ruby bool) *epubBook {
+	now := time.Now().UTC()
+	book := &epubBook{
+		ID:       fmt.Sprintf("urn:feihualing:%d", now.UnixNano()),
+		Title:    title,
+		Author:   "飞花令",
+		Modified: now.Format("2006-01-02T15:04:05Z"),
+	}
+
+	sections := make(map[string]*epubSection)
+	for _, poem := range list {
+		name := poem.Dynasty
+		if group == GroupByAuthor {
+			name = poem.Author
+		}
+		name = display(name)
+		if len(name) == 0 {
+			name = "其他"
+		}
+
+		section, ok := sections[name]
+		if !ok {
+			section = &epubSection{Name: name}
+			sections[name] = section
+			book.Sections = append(book.Sections, section)
+		}
+
+		chapter := &epubChapter{Poem: &Poem{
+			No: poem.No, Title: display(poem.Title), Dynasty: display(poem.Dynasty), Author: display(poem.Author),
+		}}
+		for _, line := range strings.Split(poem.Content, "\n") {
+			if len(strings.TrimSpace(line)) != 0 {
+				chapter.Lines = append(chapter.Lines, epubLine(line, ruby))
+			}
+		}
+		section.Chapters = append(section.Chapters, chapter)
+	}
+
+	// 分组按第一首诗出现的先后，书中的顺序与目录一致
+	for _, section := range book.Sections {
+		for _, chapter := range section.Chapters {
+			chapter.Order = len(book.Chapters) + 1
+			chapter.ID = fmt.Sprintf("poem%d", chapter.Order)
+			chapter.File = chapter.ID + ".xhtml"
+			book.Chapters = append(book.Chapters, chapter)
+		}
+	}
+	return book
+}
+
+// WriteEpub 把选中的诗做成一本电子书，每首诗一章，目录按朝代或作者分组
+func WriteEpub(writer io.Writer, title string, list []*Poem, group EpubGroup, ruby bool) error {
+	if len(list) == 0 {
+		return errors.New("没有要导出的诗")
+	}
+	book := newEpubBook(title, list, group, ruby)
+
+	zw := zip.NewWriter(writer)
+	// mimetype必须是第一个文件，并且不压缩
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, "application/epub+zip"); err != nil {
+		return err
+	}
+
+	write := func(name string, tpl *template.Template, data interface{}) error {
+		w, err := zw.Create(name)
+		if err != nil {
+			return err
+		}
+		if tpl == nil {
+			_, err = io.WriteString(w, data.(string))
+			return err
+		}
+		// html/template会转义XML声明，单独写
+		if _, err := io.WriteString(w, xml.Header); err != nil {
+			return err
+		}
+		return tpl.Execute(w, data)
+	}
+
+	if err := write("META-INF/container.xml", nil, epubContainer); err != nil {
+		return err
+	}
+	if err := write("OEBPS/style.css", nil, epubStyle); err != nil {
+		return err
+	}
+	pages := []struct {
+		name string
+		tpl  *template.Template
+	}{
+		{"OEBPS/content.opf", epubOpfTpl},
+		{"OEBPS/nav.xhtml", epubNavTpl},
+		{"OEBPS/toc.ncx", epubNcxTpl},
+		{"OEBPS/cover.svg", epubCoverSvgTpl},
+		{"OEBPS/cover.xhtml", epubCoverTpl},
+	}
+	for _, page := range pages {
+		if err := write(page.name, page.tpl, book); err != nil {
+			return err
+		}
+	}
+	for _, chapter := range book.Chapters {
+		if err := write("OEBPS/"+chapter.File, epubChapterTpl, chapter); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
@@ -0,0 +1,47 @@
+package main
+
+type ExportFormat int
+
+const (
+	ExportJSON ExportFormat = iota // 备份文件，可以再导入
+	ExportCSV
+	ExportTSV
+	ExportPDF  // 打印用的练习纸
+	ExportEPUB // 电子书
+)
+
+var ExportFormatNames = []string{"备份文件", "CSV表格", "TSV表格", "PDF练习纸", "EPUB电子书"}
+
+var exportFileNames = []string{"poems.json", "poems.csv", "poems.tsv", "poems.pdf", "poems.epub"}
+
+// ExportScope 导出哪些诗
+type ExportScope int
+
+const (
+	ScopeAll    ExportScope = iota
+	ScopeSearch             // 当前的搜索结果
+	ScopeFavor              // 当前学习者收藏的诗
+)
+
+var ExportScopeNames = []string{"全部", "当前搜索结果", "收藏"}
+
+type ExportOptions struct {
+	Format        ExportFormat
+	LineSeparator string // 表格内容中分行的符号
+	Layout        WorksheetLayout
+	Keyword       string // 飞花令练习的关键字
+	Group         EpubGroup
+	Ruby          bool // 电子书中加上拼音
+}
+
+// Scope 按范围选出要导出的诗
+func (p *Poems) Scope(scope ExportScope, s *Search) []*Poem {
+	switch scope {
+	case ScopeSearch:
+		return p.Filter(s)
+	case ScopeFavor:
+		return p.Filter(NewSearch("", true))
+	default:
+		return p.list
+	}
+}
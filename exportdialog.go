@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// showExportDialog 选择导出的格式和范围，s为主界面当前的搜索
+func showExportDialog(poems *Poems, s *Search, win fyne.Window) {
+	formatSelect := widget.NewSelect(ExportFormatNames, nil)
+	scopeSelect := widget.NewSelect(ExportScopeNames, nil)
+	separatorEntry := widget.NewEntry()
+	separatorEntry.SetPlaceHolder("表格内容中分行的符号，留空表示单元格内换行")
+	layoutSelect := widget.NewSelect(WorksheetLayoutNames, nil)
+	layoutSelect.SetSelectedIndex(int(WorksheetText))
+	keywordEntry := widget.NewEntry()
+	keywordEntry.SetPlaceHolder("飞花令练习的关键字，例如：月")
+	groupSelect := widget.NewSelect(EpubGroupNames, nil)
+	groupSelect.SetSelectedIndex(int(GroupByDynasty))
+	rubyCheck := widget.NewCheck("加上拼音", nil)
+
+	// 只有对应的格式才需要填写
+	formatSelect.OnChanged = func(string) {
+		format := ExportFormat(formatSelect.SelectedIndex())
+		if format == ExportCSV || format == ExportTSV {
+			separatorEntry.Enable()
+		} else {
+			separatorEntry.Disable()
+		}
+		if format == ExportPDF {
+			layoutSelect.Enable()
+			keywordEntry.Enable()
+		} else {
+			layoutSelect.Disable()
+			keywordEntry.Disable()
+		}
+		if format == ExportEPUB {
+			groupSelect.Enable()
+			rubyCheck.Enable()
+		} else {
+			groupSelect.Disable()
+			rubyCheck.Disable()
+		}
+	}
+	formatSelect.SetSelectedIndex(int(ExportJSON))
+	if s.HasKeyword() {
+		scopeSelect.SetSelectedIndex(int(ScopeSearch))
+	} else {
+		scopeSelect.SetSelectedIndex(int(ScopeAll))
+	}
+
+	items := []*widget.FormItem{
+		widget.NewFormItem("格式", formatSelect),
+		widget.NewFormItem("范围", scopeSelect),
+		widget.NewFormItem("分行符号", separatorEntry),
+		widget.NewFormItem("版式", layoutSelect),
+		widget.NewFormItem("关键字", keywordEntry),
+		widget.NewFormItem("目录", groupSelect),
+		widget.NewFormItem("拼音", rubyCheck),
+	}
+	dialog.ShowForm("导出", "选择文件", "取消", items, func(b bool) {
+		if !b {
+			return
+		}
+
+		opts := &ExportOptions{
+			Format:        ExportFormat(formatSelect.SelectedIndex()),
+			LineSeparator: separatorEntry.Text,
+			Layout:        WorksheetLayout(layoutSelect.SelectedIndex()),
+			Keyword:       keywordEntry.Text,
+			Group:         EpubGroup(groupSelect.SelectedIndex()),
+			Ruby:          rubyCheck.Checked,
+		}
+		list := poems.Scope(ExportScope(scopeSelect.SelectedIndex()), s)
+
+		save := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+			if err != nil || writer == nil {
+				return
+			}
+
+			if err := poems.Export(writer, list, opts); err != nil {
+				dialog.ShowError(err, win)
+			} else {
+				dialog.ShowInformation(" 提示", "导出成功", win)
+			}
+		}, win)
+		save.SetFileName(exportFileNames[opts.Format])
+		save.Show()
+	}, win)
+}
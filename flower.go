@@ -0,0 +1,295 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	ErrEmptyLine    = errors.New("请输入诗句")
+	ErrNoKeyword    = errors.New("诗句中没有关键字")
+	ErrLineNotFound = errors.New("诗库中没有这句诗")
+	ErrLineRepeated = errors.New("这句诗已经说过了")
+	ErrKeywordPos   = errors.New("关键字不在规定的位置")
+	ErrTimeout      = errors.New("超时")
+	ErrGameOver     = errors.New("本局已结束")
+)
+
+type Player struct {
+	Name     string
+	AI       *AIPlayer
+	Score    int
+	Mistakes int
+}
+
+func NewPlayer(name string) *Player {
+	return &Player{Name: name, AI: nil}
+}
+
+func NewAIPlayer(name string, level AILevel) *Player {
+	return &Player{Name: name, AI: NewAI(level)}
+}
+
+type PositionRule int
+
+const (
+	PositionAnywhere PositionRule = iota // 关键字在任意位置
+	PositionFixed                        // 关键字在固定的第N个字
+	PositionRotating                     // 关键字依次在第1、2、3……个字，到上一句的最后一个字后从头开始
+)
+
+var PositionRuleNames = []string{"任意位置", "固定位置", "轮换位置"}
+
+// MaxPosition 关键字最多在第MaxPosition个字，轮换位置时也不超过它
+const MaxPosition = 7
+
+type GameRule struct {
+	Position PositionRule
+	N        int // 固定位置时关键字在第几个字，从1开始
+
+	TurnSeconds    int // 每轮限时，0表示不限时
+	Points         int // 答对得分
+	RepeatPenalty  int // 重复扣分
+	TimeoutPenalty int // 超时扣分
+	MaxMistakes    int // 失误达到这个次数判负
+}
+
+func NewGameRule(position PositionRule, n int) *GameRule {
+	return &GameRule{
+		Position:       position,
+		N:              n,
+		TurnSeconds:    30,
+		Points:         1,
+		RepeatPenalty:  1,
+		TimeoutPenalty: 1,
+		MaxMistakes:    1,
+	}
+}
+
+func (r *GameRule) String() string {
+	switch r.Position {
+	case PositionFixed:
+		return fmt.Sprintf("%s（第%d个字）", PositionRuleNames[r.Position], r.N)
+	default:
+		return PositionRuleNames[r.Position]
+	}
+}
+
+// GameLine 玩家说出的一句诗，答错时Poem和Segment为nil
+type GameLine struct {
+	Player  *Player
+	Text    string
+	Poem    *Poem
+	Segment *Segment
+	Err     error
+}
+
+// Game 一局飞花令，玩家轮流说出含有关键字的诗句，失误次数用完的玩家输
+type Game struct {
+	Keyword string
+	Rule    *GameRule
+	Players []*Player
+	Lines   []*GameLine
+	Loser   *Player
+
+	poems    *Poems
+	search   *Search
+	turn     int
+	position int             // 轮换位置时关键字应该在第几个字，只在答对后前进
+	used     map[string]bool // 说过的诗句，不含标点并转换成简体
+}
+
+func NewGame(poems *Poems, keyword string, rule *GameRule, players []*Player) *Game {
+	s := EmptySearch()
+	s.Content = append(s.Content, keyword)
+
+	return &Game{
+		Keyword:  keyword,
+		Rule:     rule,
+		Players:  players,
+		Lines:    make([]*GameLine, 0),
+		Loser:    nil,
+		poems:    poems,
+		search:   s,
+		turn:     0,
+		position: 1,
+		used:     make(map[string]bool),
+	}
+}
+
+func (g *Game) Current() *Player {
+	return g.Players[g.turn%len(g.Players)]
+}
+
+// Position 本轮关键字应该在第几个字，0表示任意位置
+func (g *Game) Position() int {
+	switch g.Rule.Position {
+	case PositionFixed:
+		return g.Rule.N
+	case PositionRotating:
+		return g.position
+	default:
+		return 0
+	}
+}
+
+// rotate 答对后轮换到下一个位置，超过刚说的诗句的字数时从第1个字开始，
+// 这样五言诗也能一直轮换下去
+func (g *Game) rotate(text string) {
+	length := utf8.RuneCountInString(text)
+	if length > MaxPosition {
+		length = MaxPosition
+	}
+	g.position++
+	if g.position > length {
+		g.position = 1
+	}
+}
+
+// matched 诗句是否含有关键字并且位置符合规则，不区分简繁
+func (g *Game) matched(text string) bool {
+	if pos := g.Position(); pos > 0 {
+		return keywordAt(text, g.Keyword, pos)
+	}
+	return strings.Contains(normalise(text), normalise(g.Keyword))
+}
+
+func (g *Game) Over() bool {
+	return g.Loser != nil
+}
+
+// Find 在诗库中查找诗句，诗句不含标点，不区分简繁
+func (g *Game) Find(line string) (*Poem, *Segment) {
+	line = normalise(line)
+	for _, poem := range g.poems.Filter(g.search) {
+		for _, seg := range poem.Segments {
+			if normalise(stripPunctuation(seg.Content)) == line {
+				return poem, seg
+			}
+		}
+	}
+
+	return nil, nil
+}
+
+// Candidates 返回诗库中所有还没有说过的诗句
+func (g *Game) Candidates(favorOnly bool) []*GameLine {
+	s := *g.search
+	s.FavorOnly = favorOnly
+
+	candidates := make([]*GameLine, 0)
+	for _, poem := range g.poems.Filter(&s) {
+		for _, seg := range poem.Segments {
+			text := stripPunctuation(seg.Content)
+			if g.matched(seg.Text()) && !g.used[normalise(text)] {
+				candidates = append(candidates, &GameLine{Player: g.Current(), Text: text, Poem: poem, Segment: seg})
+			}
+		}
+	}
+
+	return candidates
+}
+
+func (g *Game) Check(line string) (*Poem, *Segment, error) {
+	line = stripPunctuation(line)
+	if len(line) == 0 {
+		return nil, nil, ErrEmptyLine
+	}
+
+	if !strings.Contains(normalise(line), normalise(g.Keyword)) {
+		return nil, nil, ErrNoKeyword
+	}
+
+	if g.used[normalise(line)] {
+		return nil, nil, ErrLineRepeated
+	}
+
+	poem, seg := g.Find(line)
+	if seg == nil {
+		return nil, nil, ErrLineNotFound
+	}
+
+	if !g.matched(seg.Text()) {
+		return nil, nil, ErrKeywordPos
+	}
+
+	return poem, seg, nil
+}
+
+// mistake 当前玩家失误，扣分并轮到下一位玩家
+func (g *Game) mistake(text string, err error, penalty int) *GameLine {
+	player := g.Current()
+	player.Score -= penalty
+	player.Mistakes++
+
+	l := &GameLine{Player: player, Text: text, Err: err}
+	g.Lines = append(g.Lines, l)
+	if player.Mistakes >= g.Rule.MaxMistakes {
+		g.Loser = player
+	} else {
+		g.turn++
+	}
+
+	return l
+}
+
+// Submit 当前玩家说出一句诗，答错时返回错误
+func (g *Game) Submit(line string) (*GameLine, error) {
+	if g.Over() {
+		return nil, ErrGameOver
+	}
+
+	poem, seg, err := g.Check(line)
+	if err != nil {
+		penalty := 0
+		if err == ErrLineRepeated {
+			penalty = g.Rule.RepeatPenalty
+		}
+		return g.mistake(stripPunctuation(line), err, penalty), err
+	}
+
+	player := g.Current()
+	player.Score += g.Rule.Points
+
+	text := stripPunctuation(seg.Content)
+	l := &GameLine{Player: player, Text: text, Poem: poem, Segment: seg}
+	g.Lines = append(g.Lines, l)
+	g.used[normalise(text)] = true
+	g.rotate(text)
+	g.turn++
+
+	return l, nil
+}
+
+// Timeout 当前玩家超时
+func (g *Game) Timeout() *GameLine {
+	if g.Over() {
+		return nil
+	}
+
+	return g.mistake("", ErrTimeout, g.Rule.TimeoutPenalty)
+}
+
+func (g *Game) GiveUp() {
+	if !g.Over() {
+		g.Loser = g.Current()
+	}
+}
+
+// Winner 除了输的玩家之外得分最高的玩家
+func (g *Game) Winner() *Player {
+	var winner *Player
+
+	for _, p := range g.Players {
+		if p == g.Loser {
+			continue
+		}
+		if winner == nil || p.Score > winner.Score {
+			winner = p
+		}
+	}
+
+	return winner
+}
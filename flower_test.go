@@ -0,0 +1,117 @@
+package main
+
+import "testing"
+
+func newTestGame(rule *GameRule) *Game {
+	poems := newTestPoems(
+		NewPoem(0, "春夜喜雨", "唐", "杜甫", "好雨知时节，当春乃发生。\n随风潜入夜，润物细无声。"),
+		NewPoem(0, "春晓", "唐", "孟浩然", "春眠不觉晓，处处闻啼鸟。\n夜来风雨声，花落知多少。"),
+		NewPoem(0, "春望", "唐", "杜甫", "國破山河在，城春草木深。\n感時花濺淚，恨別鳥驚心。"),
+		NewPoem(0, "江南春", "唐", "杜牧", "千里莺啼绿映红，水村山郭酒旗风。\n南朝四百八十寺，多少楼台烟雨中。"),
+	)
+	return NewGame(poems, "春", rule, []*Player{NewPlayer("甲"), NewPlayer("乙")})
+}
+
+func TestGameCheckScript(t *testing.T) {
+	tests := []struct {
+		line string
+		err  error
+	}{
+		{"当春乃发生", nil},
+		{"當春乃發生", nil}, // 繁体回答简体的诗
+		{"城春草木深", nil}, // 简体回答繁体的诗
+		{"春眠不觉晓。", nil},
+		{"处处闻啼鸟", ErrNoKeyword},
+		{"春风又绿江南岸", ErrLineNotFound},
+		{"", ErrEmptyLine},
+	}
+	for _, tt := range tests {
+		g := newTestGame(NewGameRule(PositionAnywhere, 1))
+		if _, _, err := g.Check(tt.line); err != tt.err {
+			t.Errorf("Check(%q) = %v, want %v", tt.line, err, tt.err)
+		}
+	}
+}
+
+func TestGameRepeatedScript(t *testing.T) {
+	g := newTestGame(NewGameRule(PositionAnywhere, 1))
+	g.Rule.MaxMistakes = 3
+	if _, err := g.Submit("当春乃发生"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := g.Submit("當春乃發生"); err != ErrLineRepeated {
+		t.Errorf("Submit repeated line in traditional script = %v, want %v", err, ErrLineRepeated)
+	}
+}
+
+func TestGameRotatingPosition(t *testing.T) {
+	g := newTestGame(NewGameRule(PositionRotating, 0))
+	g.Rule.MaxMistakes = 3
+
+	steps := []struct {
+		line string
+		err  error
+		next int // 提交后关键字应该在第几个字
+	}{
+		{"春眠不觉晓", nil, 2},
+		{"春眠不觉晓", ErrLineRepeated, 2}, // 答错不前进
+		{"当春乃发生", nil, 3},
+		{"江南春", ErrLineNotFound, 3},
+		{"城春草木深", ErrKeywordPos, 3},
+	}
+	for _, step := range steps {
+		if _, err := g.Submit(step.line); err != step.err {
+			t.Errorf("Submit(%q) = %v, want %v", step.line, err, step.err)
+		}
+		if pos := g.Position(); pos != step.next {
+			t.Errorf("after %q Position() = %d, want %d", step.line, pos, step.next)
+		}
+	}
+}
+
+func TestGameRotateWraps(t *testing.T) {
+	g := newTestGame(NewGameRule(PositionRotating, 0))
+	for _, tt := range []struct {
+		text string
+		want int
+	}{
+		{"好雨知时节", 2}, {"好雨知时节", 3}, {"好雨知时节", 4}, {"好雨知时节", 5},
+		{"好雨知时节", 1}, // 五言诗到第5个字后回到第1个字
+	} {
+		g.rotate(tt.text)
+		if g.position != tt.want {
+			t.Errorf("rotate(%q) position = %d, want %d", tt.text, g.position, tt.want)
+		}
+	}
+
+	g.position = 5
+	g.rotate("千里莺啼绿映红")
+	if g.position != 6 {
+		t.Errorf("rotate after a seven-character line = %d, want 6", g.position)
+	}
+}
+
+func TestGameFixedPositionLeadingSpace(t *testing.T) {
+	// 夜雨寄北的第三句在数据中以空格开头
+	poems := newTestPoems(NewPoem(0, "夜雨寄北", "唐", "李商隐", "君问归期未有期，巴山夜雨涨秋池。\n 何当共剪西窗烛，却话巴山夜雨时。"))
+	tests := []struct {
+		keyword string
+		pos     int
+		line    string
+		err     error
+	}{
+		{"何", 1, "何当共剪西窗烛", nil},
+		{"何", 2, "何当共剪西窗烛", ErrKeywordPos},
+		{"窗", 6, "何当共剪西窗烛", nil},
+		{"窗", 7, "何当共剪西窗烛", ErrKeywordPos},
+	}
+	for _, tt := range tests {
+		g := NewGame(poems, tt.keyword, NewGameRule(PositionFixed, tt.pos), []*Player{NewPlayer("甲"), NewPlayer("乙")})
+		if _, _, err := g.Check(tt.line); err != tt.err {
+			t.Errorf("%s@%d Check(%q) = %v, want %v", tt.keyword, tt.pos, tt.line, err, tt.err)
+		}
+		if candidates := g.Candidates(false); (len(candidates) == 1) != (tt.err == nil) {
+			t.Errorf("%s@%d got %d candidates", tt.keyword, tt.pos, len(candidates))
+		}
+	}
+}
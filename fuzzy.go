@@ -0,0 +1,255 @@
+package main
+
+import (
+	"github.com/mozillazg/go-pinyin"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// similarGroups 孩子容易写混的形近字，每组中的字互相算作相近
+var similarGroups = []string{
+	"己已巳", "末未", "大太犬天夭", "人入八", "土士", "日曰目自白百", "侯候", "戌戍戊",
+	"折拆析", "刀力", "免兔", "鸟乌", "贝见", "辨辩瓣", "孤狐", "暮幕墓慕募",
+	"清情晴请睛", "园圆", "住往", "洒酒", "拔拨", "木术本", "母毋", "干千于",
+	"王玉主", "问间闻", "今令", "即既", "坐座", "胡湖", "苦若", "休体",
+	"仍扔", "杨扬", "峰锋蜂", "鸣鸡", "复夏", "怜伶", "迎仰", "辛幸", "兵乒乓",
+	"买卖", "微徵", "壁璧", "燥躁", "侍待持", "宴晏", "淡谈", "帆肌", "秋愁",
+}
+
+var similarChars = func() map[rune]map[rune]bool {
+	m := make(map[rune]map[rune]bool)
+	for _, group := range similarGroups {
+		for _, a := range group {
+			if m[a] == nil {
+				m[a] = make(map[rune]bool)
+			}
+			for _, b := range group {
+				if a != b {
+					m[a][b] = true
+				}
+			}
+		}
+	}
+	return m
+}()
+
+var heteronymArgs = func() pinyin.Args {
+	args := pinyin.NewArgs()
+	args.Style = pinyin.Normal
+	args.Heteronym = true
+	return args
+}()
+
+// charReadings 字的所有读音，不带声调
+var charReadings sync.Map
+
+func readings(r rune) []string {
+	if v, ok := charReadings.Load(r); ok {
+		return v.([]string)
+	}
+	py := pinyin.SinglePinyin(r, heteronymArgs)
+	charReadings.Store(r, py)
+	return py
+}
+
+// homophone 两个字有相同的读音，不计声调
+func homophone(a, b rune) bool {
+	for _, x := range readings(a) {
+		for _, y := range readings(b) {
+			if x == y {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// 编辑的代价，同音字和形近字的替换比其他的字便宜
+const (
+	costSimilar = 1
+	costReplace = 2
+	costInsert  = 2
+)
+
+func substituteCost(a, b rune) int {
+	switch {
+	case a == b:
+		return 0
+	case homophone(a, b) || similarChars[a][b]:
+		return costSimilar
+	default:
+		return costReplace
+	}
+}
+
+// maxFuzzyCost 允许的代价，关键字越长允许错得越多，单字的同音字太多，不算
+func maxFuzzyCost(n int) int {
+	if n <= 1 {
+		return 0
+	}
+	if n == 2 {
+		return costSimilar
+	}
+	return n * 2 / 3 * costSimilar
+}
+
+// fuzzyFind 在text中找与key最接近的一段，返回这一段和编辑代价
+func fuzzyFind(text, key string) (string, int) {
+	t, k := []rune(normalise(text)), []rune(normalise(key))
+	original := []rune(text)
+	if len(k) == 0 || len(t) == 0 || len(original) != len(t) {
+		return "", -1
+	}
+
+	// cost[j]为key的前i个字与以text第j个字结尾的一段的最小代价，start[j]为这一段的起点
+	cost, start := make([]int, len(t)+1), make([]int, len(t)+1)
+	prevCost, prevStart := make([]int, len(t)+1), make([]int, len(t)+1)
+	for j := range prevStart {
+		prevStart[j] = j
+	}
+
+	for i := 1; i <= len(k); i++ {
+		cost[0], start[0] = i*costInsert, 0
+		for j := 1; j <= len(t); j++ {
+			cost[j], start[j] = prevCost[j-1]+substituteCost(k[i-1], t[j-1]), prevStart[j-1]
+			if c := prevCost[j] + costInsert; c < cost[j] {
+				cost[j], start[j] = c, prevStart[j]
+			}
+			if c := cost[j-1] + costInsert; c < cost[j] {
+				cost[j], start[j] = c, start[j-1]
+			}
+		}
+		cost, prevCost = prevCost, cost
+		start, prevStart = prevStart, start
+	}
+
+	best := -1
+	for j := 1; j <= len(t); j++ {
+		if best < 0 || prevCost[j] < prevCost[best] {
+			best = j
+		}
+	}
+	return string(original[prevStart[best]:best]), prevCost[best]
+}
+
+// FuzzyMatch 与关键字相近的诗句
+type FuzzyMatch struct {
+	Poem    *Poem
+	Segment *Segment
+	Text    string // 诗句中与关键字对应的文字
+	Cost    int
+}
+
+// fuzzyMatch 诗中与关键字最接近的诗句，没有足够接近的时ok为false
+func (p *Poem) fuzzyMatch(key string) (*FuzzyMatch, bool) {
+	limit := maxFuzzyCost(len([]rune(key)))
+	var best *FuzzyMatch
+	for _, seg := range p.Segments {
+		text, cost := fuzzyFind(seg.Text(), key)
+		if cost < 0 || cost > limit {
+			continue
+		}
+		if best == nil || cost < best.Cost {
+			best = &FuzzyMatch{Poem: p, Segment: seg, Text: text, Cost: cost}
+		}
+	}
+	return best, best != nil
+}
+
+func (p *Poem) fuzzyContains(key string) bool {
+	_, ok := p.fuzzyMatch(key)
+	return ok
+}
+
+// fuzzyTexts 诗中与关键字相近的文字，用于高亮
+func (p *Poem) fuzzyTexts(key string) []string {
+	limit := maxFuzzyCost(len([]rune(key)))
+	found := make(map[string]bool)
+	texts := make([]string, 0)
+	for _, seg := range p.Segments {
+		if text, cost := fuzzyFind(seg.Text(), key); cost >= 0 && cost <= limit && !found[text] {
+			found[text] = true
+			texts = append(texts, text)
+		}
+	}
+	return texts
+}
+
+// fuzzyCost 诗与所有关键字的编辑代价之和，含有关键字的为0
+func (p *Poem) fuzzyCost(keys []string) int {
+	total := 0
+	for _, key := range keys {
+		if p.Contains(key) {
+			continue
+		}
+		if m, ok := p.fuzzyMatch(key); ok {
+			total += m.Cost
+		}
+	}
+	return total
+}
+
+func sortByFuzzyCost(list []*Poem, keys []string) {
+	costs := make(map[*Poem]int, len(list))
+	for _, poem := range list {
+		costs[poem] = poem.fuzzyCost(keys)
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return costs[list[i]] < costs[list[j]]
+	})
+}
+
+// FuzzySearch 按与关键字的接近程度排列诗句，同样接近的按诗库的顺序
+func (p *Poems) FuzzySearch(key string, limit int) []*FuzzyMatch {
+	key = stripPunctuation(key)
+	if len(key) == 0 || isPinyinKey(key) {
+		return nil
+	}
+
+	maxCost := maxFuzzyCost(len([]rune(key)))
+	matches := make([]*FuzzyMatch, 0)
+	for _, poem := range p.list {
+		for _, seg := range poem.Segments {
+			text, cost := fuzzyFind(seg.Text(), key)
+			if cost >= 0 && cost <= maxCost {
+				matches = append(matches, &FuzzyMatch{Poem: poem, Segment: seg, Text: text, Cost: cost})
+			}
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].Cost < matches[j].Cost
+	})
+	if limit > 0 && len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches
+}
+
+// Suggest 精确搜索没有结果时，把内容关键字换成诗库中相近的文字，返回能搜到诗的规则
+func (p *Poems) Suggest(rule string, s *Search, limit int) []string {
+	suggestions := make([]string, 0, limit)
+	found := map[string]bool{rule: true}
+	for _, key := range s.Content {
+		for _, m := range p.FuzzySearch(key, 0) {
+			if len(suggestions) == limit {
+				return suggestions
+			}
+
+			suggestion := strings.Replace(rule, key, m.Text, 1)
+			if found[suggestion] || normalise(m.Text) == normalise(key) {
+				continue
+			}
+
+			// 其他条件也要满足
+			candidate := NewSearch(suggestion, s.FavorOnly)
+			candidate.MemorisedOnly = s.MemorisedOnly
+			if m.Poem.Matched(candidate) {
+				found[suggestion] = true
+				suggestions = append(suggestions, suggestion)
+			}
+		}
+	}
+	return suggestions
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestMaxFuzzyCost(t *testing.T) {
+	tests := []struct {
+		n, want int
+	}{
+		{0, 0},
+		{1, 0},
+		{2, 1},
+		{3, 2},
+		{4, 2},
+		{5, 3},
+		{7, 4},
+	}
+	for _, tt := range tests {
+		if got := maxFuzzyCost(tt.n); got != tt.want {
+			t.Errorf("maxFuzzyCost(%d) = %d, want %d", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestSubstituteCost(t *testing.T) {
+	tests := []struct {
+		a, b rune
+		want int
+	}{
+		{'明', '明', 0},
+		{'明', '名', costSimilar}, // 同音
+		{'己', '已', costSimilar}, // 形近
+		{'明', '暗', costReplace},
+	}
+	for _, tt := range tests {
+		if got := substituteCost(tt.a, tt.b); got != tt.want {
+			t.Errorf("substituteCost(%c, %c) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestFuzzyFind(t *testing.T) {
+	tests := []struct {
+		text, key string
+		want      string
+		cost      int
+	}{
+		{"床前明月光", "明月", "明月", 0},
+		{"床前明月光", "名月", "明月", costSimilar},
+		{"床前明月光", "床前明月", "床前明月", 0},
+		{"床前明月光", "床前月光", "床前明月光", costInsert},
+		{"床前明月光", "床前明明月光", "床前明月光", costInsert},
+		{"床前明月光", "床钱暗月", "床前明月", costSimilar + costReplace},
+		{"長安一片月", "长安", "長安", 0},
+		{"長安一片月", "常安", "長安", costSimilar},
+		{"", "明月", "", -1},
+		{"床前明月光", "", "", -1},
+	}
+	for _, tt := range tests {
+		got, cost := fuzzyFind(tt.text, tt.key)
+		if got != tt.want || cost != tt.cost {
+			t.Errorf("fuzzyFind(%q, %q) = %q, %d, want %q, %d", tt.text, tt.key, got, cost, tt.want, tt.cost)
+		}
+	}
+}
+
+func newFuzzyTestPoems() *Poems {
+	return newTestPoems(
+		NewPoem(0, "静夜思", "唐代", "李白", "床前明月光，\n疑是地上霜。\n举头望明月，\n低头思故乡。"),
+		NewPoem(0, "子夜吴歌", "唐代", "李白", "長安一片月，\n萬戶擣衣聲。\n秋風吹不盡，\n總是玉關情。"),
+		NewPoem(0, "登鹳雀楼", "唐代", "王之涣", "白日依山尽，\n黄河入海流。\n欲穷千里目，\n更上一层楼。"),
+	)
+}
+
+func TestFuzzySearch(t *testing.T) {
+	p := newFuzzyTestPoems()
+	tests := []struct {
+		key   string
+		limit int
+		want  []string // 诗句中对应的文字，按接近程度
+		cost  []int
+	}{
+		{"举头望名月", 0, []string{"举头望明月"}, []int{costSimilar}},
+		{"名月", 0, []string{"明月", "明月"}, []int{costSimilar, costSimilar}},
+		{"名月", 1, []string{"明月"}, []int{costSimilar}},
+		{"长安一片月", 0, []string{"長安一片月"}, []int{0}},
+		{"白日依山进，", 0, []string{"白日依山尽"}, []int{costSimilar}},
+		{"欲穷千里", 0, []string{"欲穷千里"}, []int{0}},
+		{"月", 0, []string{"月", "月", "月"}, []int{0, 0, 0}},
+		{"悦", 0, []string{}, []int{}}, // 单字不找同音字
+		{"mingyue", 0, []string{}, []int{}},
+		{"鲸鱼海浪", 0, []string{}, []int{}},
+		{"，", 0, []string{}, []int{}},
+	}
+	for _, tt := range tests {
+		matches := p.FuzzySearch(tt.key, tt.limit)
+		got, cost := make([]string, len(matches)), make([]int, len(matches))
+		for i, m := range matches {
+			got[i], cost[i] = m.Text, m.Cost
+		}
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") || fmt.Sprint(cost) != fmt.Sprint(tt.cost) {
+			t.Errorf("FuzzySearch(%q, %d) = %v %v, want %v %v", tt.key, tt.limit, got, cost, tt.want, tt.cost)
+		}
+	}
+}
+
+func TestFuzzySearchOrder(t *testing.T) {
+	p := newFuzzyTestPoems()
+	matches := p.FuzzySearch("明月光", 0)
+	if len(matches) == 0 || matches[0].Poem.Title != "静夜思" || matches[0].Segment.Seq != 0 || matches[0].Cost != 0 {
+		t.Fatalf("best match = %+v", matches)
+	}
+	for i := 1; i < len(matches); i++ {
+		if matches[i].Cost < matches[i-1].Cost {
+			t.Errorf("matches not sorted by cost: %d after %d", matches[i].Cost, matches[i-1].Cost)
+		}
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	p := newFuzzyTestPoems()
+	tests := []struct {
+		rule string
+		want []string
+	}{
+		{"举头望名月", []string{"举头望明月"}},
+		{"名月 a李白", []string{"明月 a李白"}},
+		{"名月 a王之涣", []string{}},
+		{"白日依山进 黄河", []string{"白日依山尽 黄河"}},
+		{"长安", []string{}}, // 本来就能搜到
+		{"鲸鱼海浪", []string{}},
+	}
+	for _, tt := range tests {
+		got := p.Suggest(tt.rule, NewSearch(tt.rule, false), 3)
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("Suggest(%q) = %q, want %q", tt.rule, got, tt.want)
+		}
+	}
+}
+
+func TestFuzzyFilterSortsByCost(t *testing.T) {
+	p := newTestPoems(
+		NewPoem(0, "一", "唐代", "甲", "白日依山进，\n黄河入海流。"),
+		NewPoem(0, "二", "唐代", "乙", "白日依山尽，\n黄河入海流。"),
+	)
+	s := NewSearch("白日依山尽", false)
+	s.Fuzzy = true
+	if got := poemTitles(p.Filter(s)); got != "二,一" {
+		t.Errorf("Filter = [%s], want [二,一]", got)
+	}
+}
@@ -0,0 +1,354 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/data/binding"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+type GameScreen struct {
+	root  fyne.CanvasObject
+	reset func()
+}
+
+func newIntEntry(value int, min int, max int) *widget.Entry {
+	e := widget.NewEntry()
+	e.SetText(strconv.Itoa(value))
+	e.Validator = func(s string) error {
+		if n, err := strconv.Atoi(strings.TrimSpace(s)); err != nil || n < min || n > max {
+			return fmt.Errorf("请输入%d到%d之间的数字", min, max)
+		}
+		return nil
+	}
+	return e
+}
+
+func intEntryValue(e *widget.Entry) int {
+	n, _ := strconv.Atoi(strings.TrimSpace(e.Text))
+	return n
+}
+
+func NewGameScreen(poems *Poems, mgr *ScreenManager, win fyne.Window) *GameScreen {
+	var game *Game
+	var lock sync.Mutex // 计时器在另外的goroutine中修改game
+	turnId := 0         // 每轮加一，使上一轮的计时器失效
+
+	defaultRule := NewGameRule(PositionAnywhere, 1)
+
+	keyword := widget.NewEntry()
+	keyword.SetPlaceHolder("例如：花")
+	keyword.Validator = func(s string) error {
+		if utf8.RuneCountInString(strings.TrimSpace(s)) != 1 {
+			return errors.New("关键字必须是一个字")
+		}
+		return nil
+	}
+	players := widget.NewEntry()
+	players.SetPlaceHolder("玩家名字，用空格分隔")
+	players.SetText("甲 乙")
+	const noAI = "无"
+	aiLevel := widget.NewSelect(append([]string{noAI}, AILevelNames...), nil)
+	aiLevel.SetSelected(noAI)
+	position := newIntEntry(defaultRule.N, 1, MaxPosition)
+	position.Disable()
+	positionRule := widget.NewSelect(PositionRuleNames, func(s string) {
+		if s == PositionRuleNames[PositionFixed] {
+			position.Enable()
+		} else {
+			position.Disable()
+		}
+	})
+	positionRule.SetSelectedIndex(int(PositionAnywhere))
+	turnSeconds := newIntEntry(defaultRule.TurnSeconds, 0, 600)
+	points := newIntEntry(defaultRule.Points, 0, 100)
+	repeatPenalty := newIntEntry(defaultRule.RepeatPenalty, 0, 100)
+	timeoutPenalty := newIntEntry(defaultRule.TimeoutPenalty, 0, 100)
+	maxMistakes := newIntEntry(defaultRule.MaxMistakes, 1, 100)
+
+	info := widget.NewLabel("")
+	scores := widget.NewLabel("")
+	remaining := binding.NewString()
+	lineData := binding.NewUntypedList()
+	lineList := widget.NewListWithData(lineData,
+		func() fyne.CanvasObject {
+			return widget.NewLabel("")
+		},
+		func(item binding.DataItem, o fyne.CanvasObject) {
+			item.AddListener(binding.NewDataListener(func() {
+				i, _ := item.(binding.Untyped).Get()
+				l := i.(*GameLine)
+				if l.Err != nil {
+					o.(*widget.Label).SetText(fmt.Sprintf("%s：%s  ✗ %s", l.Player.Name, l.Text, l.Err.Error()))
+				} else {
+					o.(*widget.Label).SetText(fmt.Sprintf("%s：%s  —— %s", l.Player.Name, display(l.Segment.Text()), l.Poem.Abstract()))
+				}
+			}))
+		})
+	lineEntry := widget.NewEntry()
+	lineEntry.SetPlaceHolder("请输入含有关键字的诗句")
+
+	var setup, play fyne.CanvasObject
+
+	updateInfo := func() {
+		if game.Over() {
+			info.SetText(fmt.Sprintf("关键字：%s    %s 输了", game.Keyword, game.Loser.Name))
+		} else if pos := game.Position(); pos > 0 {
+			info.SetText(fmt.Sprintf("关键字：%s 在第%d个字    轮到：%s", game.Keyword, pos, game.Current().Name))
+		} else {
+			info.SetText(fmt.Sprintf("关键字：%s    轮到：%s", game.Keyword, game.Current().Name))
+		}
+
+		parts := make([]string, 0, len(game.Players))
+		for _, p := range game.Players {
+			parts = append(parts, fmt.Sprintf("%s %d分 失误%d/%d", p.Name, p.Score, p.Mistakes, game.Rule.MaxMistakes))
+		}
+		scores.SetText(strings.Join(parts, "    "))
+
+		lines := make([]interface{}, len(game.Lines))
+		for i := range lines {
+			lines[i] = game.Lines[i]
+		}
+		_ = lineData.Set(lines)
+		lineList.ScrollToBottom()
+	}
+
+	// 以下函数调用时需要持有lock
+	finish := func(reason string) {
+		turnId++
+		_ = remaining.Set("")
+		updateInfo()
+
+		msg := fmt.Sprintf("%s，%s 输了", reason, game.Loser.Name)
+		if winner := game.Winner(); winner != nil {
+			msg = fmt.Sprintf("%s，%s 获胜", msg, winner.Name)
+		}
+		dialog.ShowInformation("本局结束", msg, win)
+
+		if err := NewGameRecord(game).Save(); err != nil {
+			dialog.ShowError(err, win)
+		}
+	}
+
+	var startTurn func()
+
+	playAI := func() {
+		for !game.Over() && game.Current().AI != nil {
+			player := game.Current()
+			line, ok := player.AI.Answer(game)
+			if !ok {
+				// 想不起来与超时一样算失误
+				game.Timeout()
+				if game.Over() {
+					finish(fmt.Sprintf("%s 想不起来了", player.Name))
+					return
+				}
+				continue
+			}
+
+			if _, err := game.Submit(line); err != nil && game.Over() {
+				finish(fmt.Sprintf("%s：%s", line, err.Error()))
+				return
+			}
+		}
+		startTurn()
+	}
+
+	timeout := func() {
+		player := game.Current()
+		game.Timeout()
+		if game.Over() {
+			finish(fmt.Sprintf("%s 超时", player.Name))
+			return
+		}
+		playAI()
+	}
+
+	startTurn = func() {
+		turnId++
+		updateInfo()
+		if game.Over() || game.Rule.TurnSeconds <= 0 {
+			_ = remaining.Set("")
+			return
+		}
+
+		id, left := turnId, game.Rule.TurnSeconds
+		_ = remaining.Set(fmt.Sprintf("剩余%d秒", left))
+		go func() {
+			for left > 0 {
+				time.Sleep(time.Second)
+
+				lock.Lock()
+				if id != turnId {
+					lock.Unlock()
+					return
+				}
+				left--
+				_ = remaining.Set(fmt.Sprintf("剩余%d秒", left))
+				if left == 0 {
+					timeout()
+				}
+				lock.Unlock()
+			}
+		}()
+	}
+
+	submit := func() {
+		lock.Lock()
+		defer lock.Unlock()
+
+		if game == nil || game.Over() {
+			return
+		}
+
+		line := lineEntry.Text
+		lineEntry.SetText("")
+		if _, err := game.Submit(line); err != nil {
+			if game.Over() {
+				finish(fmt.Sprintf("%s：%s", stripPunctuation(line), err.Error()))
+				return
+			}
+			dialog.ShowInformation("失误", fmt.Sprintf("%s：%s", stripPunctuation(line), err.Error()), win)
+		}
+
+		playAI()
+	}
+	lineEntry.OnSubmitted = func(string) {
+		submit()
+	}
+
+	startBtn := widget.NewButtonWithIcon("开始", theme.MediaPlayIcon(), func() {
+		if err := keyword.Validate(); err != nil {
+			dialog.ShowError(err, win)
+			return
+		}
+
+		for _, e := range []*widget.Entry{turnSeconds, points, repeatPenalty, timeoutPenalty, maxMistakes} {
+			if err := e.Validate(); err != nil {
+				dialog.ShowError(err, win)
+				return
+			}
+		}
+
+		rule := NewGameRule(PositionRule(positionRule.SelectedIndex()), 0)
+		if rule.Position == PositionFixed {
+			if err := position.Validate(); err != nil {
+				dialog.ShowError(err, win)
+				return
+			}
+			rule.N = intEntryValue(position)
+		}
+		rule.TurnSeconds = intEntryValue(turnSeconds)
+		rule.Points = intEntryValue(points)
+		rule.RepeatPenalty = intEntryValue(repeatPenalty)
+		rule.TimeoutPenalty = intEntryValue(timeoutPenalty)
+		rule.MaxMistakes = intEntryValue(maxMistakes)
+
+		names := strings.Fields(players.Text)
+		ps := make([]*Player, 0, len(names)+1)
+		for _, name := range names {
+			ps = append(ps, NewPlayer(name))
+		}
+		if i := aiLevel.SelectedIndex(); i > 0 {
+			ai := NewAIPlayer("电脑", AILevel(i-1))
+			if ai.AI.PreferRare {
+				usage, err := PoemUsage()
+				if err != nil {
+					dialog.ShowError(err, win)
+					return
+				}
+				ai.AI.Usage = usage
+			}
+			ps = append(ps, ai)
+		}
+		if len(ps) < 2 {
+			dialog.ShowError(errors.New("至少需要两名玩家"), win)
+			return
+		}
+
+		lock.Lock()
+		defer lock.Unlock()
+
+		game = NewGame(poems, strings.TrimSpace(keyword.Text), rule, ps)
+		lineEntry.SetText("")
+		setup.Hide()
+		play.Show()
+		playAI()
+	})
+	returnBtn := widget.NewButtonWithIcon("返回", theme.NavigateBackIcon(), func() {
+		mgr.SwitchTo("entry")
+	})
+	historyBtn := widget.NewButtonWithIcon("历史", theme.HistoryIcon(), func() {
+		mgr.SwitchTo("history")
+	})
+	setup = container.NewBorder(nil, container.NewGridWithColumns(3, returnBtn, historyBtn, startBtn), nil, nil,
+		container.NewVScroll(widget.NewForm(
+			widget.NewFormItem("关键字", keyword),
+			widget.NewFormItem("规则", positionRule), widget.NewFormItem("位置", position),
+			widget.NewFormItem("玩家", players), widget.NewFormItem("电脑对手", aiLevel),
+			widget.NewFormItem("每轮秒数", turnSeconds), widget.NewFormItem("答对得分", points),
+			widget.NewFormItem("重复扣分", repeatPenalty), widget.NewFormItem("超时扣分", timeoutPenalty),
+			widget.NewFormItem("失误次数", maxMistakes))))
+
+	submitBtn := widget.NewButtonWithIcon("提交", theme.ConfirmIcon(), submit)
+	giveUpBtn := widget.NewButtonWithIcon("认输", theme.CancelIcon(), func() {
+		lock.Lock()
+		defer lock.Unlock()
+
+		if game == nil || game.Over() {
+			return
+		}
+
+		game.GiveUp()
+		finish(fmt.Sprintf("%s 认输", game.Loser.Name))
+	})
+	newGameBtn := widget.NewButtonWithIcon("新一局", theme.ViewRefreshIcon(), func() {
+		lock.Lock()
+		defer lock.Unlock()
+
+		turnId++
+		play.Hide()
+		setup.Show()
+	})
+	play = container.NewBorder(container.NewVBox(container.NewBorder(nil, nil, nil, widget.NewLabelWithData(remaining), info), scores),
+		container.NewVBox(container.NewBorder(nil, nil, nil, submitBtn, lineEntry), container.NewGridWithColumns(2, newGameBtn, giveUpBtn)),
+		nil, nil, lineList)
+
+	reset := func() {
+		lock.Lock()
+		defer lock.Unlock()
+
+		turnId++
+		game = nil
+		_ = lineData.Set(make([]interface{}, 0))
+		_ = remaining.Set("")
+		play.Hide()
+		setup.Show()
+	}
+	reset()
+
+	return &GameScreen{root: container.NewMax(setup, play), reset: reset}
+}
+
+func (s *GameScreen) Show(interface{}) {
+	s.reset()
+	s.root.Show()
+}
+
+func (s *GameScreen) Hide() {
+	s.reset()
+	s.root.Hide()
+}
+
+func (s *GameScreen) RootObj() fyne.CanvasObject {
+	return s.root
+}
@@ -4,6 +4,8 @@ go 1.18
 
 require (
 	fyne.io/fyne/v2 v2.3.0
+	github.com/jung-kurt/gofpdf v1.16.2
+	github.com/mozillazg/go-pinyin v0.20.0
 	gorm.io/driver/sqlite v1.4.4
 	gorm.io/gorm v1.24.3
 )
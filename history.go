@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/data/binding"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+type HistoryScreen struct {
+	root   fyne.CanvasObject
+	update func()
+}
+
+func NewHistoryScreen(mgr *ScreenManager, win fyne.Window) *HistoryScreen {
+	recordData := binding.NewUntypedList()
+
+	var updateList func()
+
+	showRecord := func(r *GameRecord) {
+		text := widget.NewRichTextFromMarkdown(r.DetailMarkdown())
+		text.Wrapping = fyne.TextWrapWord
+		scroll := container.NewVScroll(text)
+		scroll.SetMinSize(fyne.NewSize(400, 400))
+
+		var d dialog.Dialog
+		delBtn := widget.NewButtonWithIcon("删除", theme.DeleteIcon(), func() {
+			dialog.ShowConfirm("警告", "删除这条记录？", func(b bool) {
+				if !b {
+					return
+				}
+
+				if err := RemoveGameRecord(r); err != nil {
+					dialog.ShowError(err, win)
+					return
+				}
+				d.Hide()
+				updateList()
+			}, win)
+		})
+		d = dialog.NewCustom("记录", "关闭", container.NewBorder(nil, delBtn, nil, nil, scroll), win)
+		d.Show()
+	}
+
+	recordList := widget.NewListWithData(recordData,
+		func() fyne.CanvasObject {
+			abstract := widget.NewLabel("")
+			showDetailBtn := widget.NewButtonWithIcon("", theme.NavigateNextIcon(), func() {})
+			return container.NewBorder(nil, nil, showDetailBtn, nil, abstract)
+		},
+		func(item binding.DataItem, o fyne.CanvasObject) {
+			item.AddListener(binding.NewDataListener(func() {
+				i, _ := item.(binding.Untyped).Get()
+				r := i.(*GameRecord)
+
+				objs := o.(*fyne.Container).Objects
+				abstract, showDetailBtn := objs[0].(*widget.Label), objs[1].(*widget.Button)
+
+				abstract.SetText(r.Abstract())
+				showDetailBtn.OnTapped = func() {
+					showRecord(r)
+				}
+			}))
+		})
+
+	updateList = func() {
+		records, err := LoadGameRecords()
+		if err != nil {
+			dialog.ShowError(err, win)
+			return
+		}
+
+		list := make([]interface{}, len(records))
+		for i := range list {
+			list[i] = records[i]
+		}
+		_ = recordData.Set(list)
+	}
+
+	returnBtn := widget.NewButtonWithIcon("返回", theme.NavigateBackIcon(), func() {
+		mgr.SwitchTo("game")
+	})
+
+	return &HistoryScreen{
+		root:   container.NewBorder(nil, returnBtn, nil, nil, recordList),
+		update: updateList,
+	}
+}
+
+func (s *HistoryScreen) Show(interface{}) {
+	s.update()
+	s.root.Show()
+}
+
+func (s *HistoryScreen) Hide() {
+	s.root.Hide()
+}
+
+func (s *HistoryScreen) RootObj() fyne.CanvasObject {
+	return s.root
+}
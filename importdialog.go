@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+func showImportError(err error, win fyne.Window) {
+	dialog.ShowError(fmt.Errorf("导入失败，没有改动现有的诗\n%w", err), win)
+}
+
+// showColumnsDialog 设置表格每一列对应的字段，按表头预先选好
+func showColumnsDialog(rows [][]string, comma rune, win fyne.Window, onConfirm func(opts *TableOptions)) {
+	n := 0
+	for _, row := range rows {
+		if len(row) > n {
+			n = len(row)
+		}
+	}
+
+	guessed, header := GuessColumns(rows[0])
+	headerCheck := widget.NewCheck("第一行是表头", nil)
+	headerCheck.SetChecked(header)
+	separatorEntry := widget.NewEntry()
+	separatorEntry.SetPlaceHolder("内容中分行的符号，例如 /，留空表示单元格内换行")
+
+	selects := make([]*widget.Select, n)
+	items := make([]*widget.FormItem, 0, n+2)
+	items = append(items, widget.NewFormItem("", headerCheck), widget.NewFormItem("分行符号", separatorEntry))
+	for i := range selects {
+		selects[i] = widget.NewSelect(TableColumnNames, nil)
+		if i < len(guessed) {
+			selects[i].SetSelectedIndex(int(guessed[i]))
+		} else {
+			selects[i].SetSelectedIndex(int(ColumnIgnore))
+		}
+
+		// 显示表头或第一行的内容，方便辨认
+		sample := ""
+		if i < len(rows[0]) {
+			sample = rows[0][i]
+			if r := []rune(sample); len(r) > 8 {
+				sample = string(r[:8]) + "…"
+			}
+		}
+		items = append(items, widget.NewFormItem(fmt.Sprintf("第%d列 %s", i+1, sample), selects[i]))
+	}
+
+	dialog.ShowForm("设置表格的列", "导入", "取消", items, func(b bool) {
+		if !b {
+			return
+		}
+
+		opts := &TableOptions{Comma: comma, Header: headerCheck.Checked, LineSeparator: separatorEntry.Text, Columns: make([]TableColumn, n)}
+		for i, s := range selects {
+			opts.Columns[i] = TableColumn(s.SelectedIndex())
+		}
+		onConfirm(opts)
+	}, win)
+}
+
+// showImportDialog 选择导入方式和文件，合并时先预览再写入
+func showImportDialog(poems *Poems, win fyne.Window, onImported func()) {
+	formatSelect := widget.NewSelect(ImportFormatNames, nil)
+	formatSelect.SetSelectedIndex(int(ImportJSON))
+	modeSelect := widget.NewSelect(ImportModeNames, nil)
+	modeSelect.SetSelectedIndex(int(ImportMerge))
+	policySelect := widget.NewSelect(ConflictPolicyNames, nil)
+	policySelect.SetSelectedIndex(int(ConflictKeep))
+	scriptSelect := widget.NewSelect(ScriptNames, nil)
+	scriptSelect.SetSelectedIndex(int(ScriptOriginal))
+	ruleEntry := widget.NewEntry()
+	ruleEntry.SetPlaceHolder("只导入符合搜索规则的诗，例如：a李白 月")
+
+	// 数据集是繁体的，默认转换成简体
+	formatSelect.OnChanged = func(string) {
+		if ImportFormat(formatSelect.SelectedIndex()) == ImportDataset {
+			scriptSelect.SetSelectedIndex(int(ScriptSimplified))
+		}
+	}
+
+	items := []*widget.FormItem{
+		widget.NewFormItem("格式", formatSelect),
+		widget.NewFormItem("方式", modeSelect),
+		widget.NewFormItem("不一致时", policySelect),
+		widget.NewFormItem("转换为", scriptSelect),
+		widget.NewFormItem("筛选", ruleEntry),
+	}
+	dialog.ShowForm("导入", "选择文件", "取消", items, func(b bool) {
+		if !b {
+			return
+		}
+
+		format, mode, policy := ImportFormat(formatSelect.SelectedIndex()), ImportMode(modeSelect.SelectedIndex()), ConflictPolicy(policySelect.SelectedIndex())
+		script, rule := Script(scriptSelect.SelectedIndex()), ruleEntry.Text
+		dialog.ShowFileOpen(func(reader fyne.URIReadCloser, err error) {
+			if err != nil || reader == nil {
+				return
+			}
+
+			defer func() {
+				err = reader.Close()
+			}()
+
+			apply := func(list []*Poem) {
+				if mode == ImportReplace {
+					replace := func() {
+						if err := poems.Import(list); err != nil {
+							showImportError(err, win)
+						}
+						onImported()
+					}
+
+					// 删除的诗的学习记录无法恢复，先确认
+					removed := poems.ReplaceRemoved(list)
+					if len(removed) == 0 {
+						replace()
+						return
+					}
+					dialog.ShowConfirm("替换全部",
+						fmt.Sprintf("文件中没有的 %d 首诗将被删除，所有学习者在这些诗上的收藏、复习计划和练习统计也会删除，继续吗？", len(removed)),
+						func(b bool) {
+							if b {
+								replace()
+							}
+						}, win)
+					return
+				}
+
+				// 预览确认后才写入
+				plan := poems.PlanMerge(list, policy)
+				preview := widget.NewRichTextFromMarkdown(plan.Markdown())
+				preview.Wrapping = fyne.TextWrapWord
+				scroll := container.NewVScroll(preview)
+				scroll.SetMinSize(fyne.NewSize(400, 400))
+				dialog.ShowCustomConfirm("导入预览", "导入", "取消", scroll, func(b bool) {
+					if !b {
+						return
+					}
+
+					if err := poems.Merge(plan); err != nil {
+						showImportError(err, win)
+					}
+					onImported()
+				}, win)
+			}
+
+			if !format.IsTable() {
+				list, warnings, err := ReadImport(format, reader.URI().Name(), reader, script, rule)
+				if err != nil {
+					showImportError(err, win)
+					return
+				}
+				if len(warnings) == 0 {
+					apply(list)
+					return
+				}
+
+				// 只有提醒时让用户决定是否继续
+				dialog.ShowConfirm("导入提醒", fmt.Sprintf("%s\n\n仍然导入吗？", warnings.Error()), func(b bool) {
+					if b {
+						apply(list)
+					}
+				}, win)
+				return
+			}
+
+			table, err := ReadTable(reader, format.Comma())
+			if err != nil {
+				showImportError(err, win)
+				return
+			}
+			showColumnsDialog(table.Rows, format.Comma(), win, func(opts *TableOptions) {
+				list, err := ParseTable(table, opts)
+				if err == nil {
+					list, err = PrepareImport(list, script, rule)
+				}
+				if err != nil {
+					showImportError(err, win)
+					return
+				}
+				apply(list)
+			})
+		}, win)
+	}, win)
+}
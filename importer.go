@@ -0,0 +1,410 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"fyne.io/fyne/v2"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+type ImportFormat int
+
+const (
+	ImportJSON    ImportFormat = iota // 本app导出的文件
+	ImportDataset                     // chinese-poetry数据集
+	ImportCSV
+	ImportTSV
+)
+
+var ImportFormatNames = []string{"备份文件", "chinese-poetry数据集", "CSV表格", "TSV表格"}
+
+// IsTable 表格需要先设置每一列对应的字段
+func (f ImportFormat) IsTable() bool {
+	return f == ImportCSV || f == ImportTSV
+}
+
+func (f ImportFormat) Comma() rune {
+	if f == ImportTSV {
+		return '\t'
+	}
+	return ','
+}
+
+// ReadImport 按格式读取并检查要导入的诗，表格用ParseTable读取。
+// warnings是不影响导入的问题，导入前给用户看
+func ReadImport(format ImportFormat, name string, reader io.Reader, script Script, rule string) ([]*Poem, ImportErrors, error) {
+	var list []*Poem
+	var warnings ImportErrors
+	var err error
+	switch format {
+	case ImportDataset:
+		list, err = ReadDataset(name, reader)
+	default:
+		list, warnings, err = readPoems(reader)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	list, err = PrepareImport(list, script, rule)
+	return list, warnings, err
+}
+
+// PrepareImport 可以把诗统一转换成简体或繁体，rule不为空时只保留符合搜索规则的诗
+func PrepareImport(list []*Poem, script Script, rule string) ([]*Poem, error) {
+	if script != ScriptOriginal {
+		for _, poem := range list {
+			poem.Convert(script)
+		}
+	}
+
+	if len(strings.TrimSpace(rule)) != 0 {
+		filter := NewPoems()
+		filter.setList(list)
+		list = filter.Filter(NewSearch(rule, false))
+		if len(list) == 0 {
+			return nil, errors.New("没有符合筛选条件的诗")
+		}
+	}
+
+	return list, nil
+}
+
+// ImportError 导入文件中出错的位置，Index为第几首诗，从1开始，0表示不在某首诗中
+type ImportError struct {
+	Index int
+	Line  int
+	Field string
+	Err   error
+}
+
+func (e *ImportError) Error() string {
+	var b strings.Builder
+	if e.Index > 0 {
+		fmt.Fprintf(&b, "第%d首诗", e.Index)
+	}
+	if e.Line > 0 {
+		fmt.Fprintf(&b, "（第%d行）", e.Line)
+	}
+	if len(e.Field) != 0 {
+		fmt.Fprintf(&b, "%s字段", e.Field)
+	}
+	if b.Len() != 0 {
+		b.WriteString("：")
+	}
+	b.WriteString(e.Err.Error())
+	return b.String()
+}
+
+func (e *ImportError) Unwrap() error {
+	return e.Err
+}
+
+// ImportErrors 检查导入文件时发现的所有问题
+type ImportErrors []*ImportError
+
+func (errs ImportErrors) Error() string {
+	const MaxShown = 10
+
+	lines := make([]string, 0, MaxShown+1)
+	for i, err := range errs {
+		if i == MaxShown {
+			lines = append(lines, fmt.Sprintf("……共%d个问题", len(errs)))
+			break
+		}
+		lines = append(lines, err.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// lineAt offset所在的行，从1开始
+func lineAt(data []byte, offset int64) int {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	return bytes.Count(data[:offset], []byte("\n")) + 1
+}
+
+func skipSpace(data []byte, offset int64) int64 {
+	for offset < int64(len(data)) && strings.ContainsRune(" \t\r\n", rune(data[offset])) {
+		offset++
+	}
+	return offset
+}
+
+// decodeError 把json的错误转换成带位置的ImportError，
+// base为解析的内容在文件中的位置，json错误中的偏移量从这里算起，start为这首诗在文件中的位置
+func decodeError(data []byte, index int, base int64, start int64, err error) *ImportError {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr) && base+syntaxErr.Offset >= int64(len(data)):
+		return &ImportError{Line: lineAt(data, int64(len(data))), Err: errors.New("文件不完整")}
+	case errors.As(err, &syntaxErr):
+		return &ImportError{Index: index, Line: lineAt(data, base+syntaxErr.Offset), Err: errors.New("格式错误")}
+	case errors.As(err, &typeErr):
+		return &ImportError{Index: index, Line: lineAt(data, base+typeErr.Offset), Field: typeErr.Field,
+			Err: fmt.Errorf("应为%s，实际为%s", typeErr.Type, typeErr.Value)}
+	case errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF):
+		return &ImportError{Line: lineAt(data, int64(len(data))), Err: errors.New("文件不完整")}
+	default:
+		return &ImportError{Index: index, Line: lineAt(data, skipSpace(data, start)), Err: err}
+	}
+}
+
+const (
+	exportFormat  = "feihualing"
+	exportVersion = 2 // 2 增加了体裁、标签、出处、备注、译文和注释
+)
+
+// ExportHeader 导出文件的头，旧版本导出的是不带头的数组
+type ExportHeader struct {
+	Format   string    `json:"format"`
+	Version  int       `json:"version"`
+	App      string    `json:"app,omitempty"`
+	Exported time.Time `json:"exported"`
+	Profile  string    `json:"profile,omitempty"`
+	Count    int       `json:"count"`
+	Checksum string    `json:"checksum"`
+}
+
+type exportFile struct {
+	ExportHeader
+	Poems json.RawMessage `json:"poems"`
+}
+
+// checksum 与缩进无关的校验和
+func checksum(poems json.RawMessage) (string, error) {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, poems); err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(buf.Bytes())
+	return "sha256:" + hex.EncodeToString(sum[:]), nil
+}
+
+func NewExportHeader(profile string, count int, poems json.RawMessage) (*ExportHeader, error) {
+	sum, err := checksum(poems)
+	if err != nil {
+		return nil, err
+	}
+
+	header := &ExportHeader{
+		Format:   exportFormat,
+		Version:  exportVersion,
+		Exported: time.Now(),
+		Profile:  profile,
+		Count:    count,
+		Checksum: sum,
+	}
+	if app := fyne.CurrentApp(); app != nil {
+		header.App = app.Metadata().Version
+	}
+	return header, nil
+}
+
+// encodeExport 带头的导出文件
+func encodeExport(profile string, list []*Poem) ([]byte, error) {
+	poems, err := json.Marshal(list)
+	if err != nil {
+		return nil, err
+	}
+
+	header, err := NewExportHeader(profile, len(list), poems)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.MarshalIndent(&exportFile{ExportHeader: *header, Poems: poems}, "", "  ")
+}
+
+// poemFields 导出文件中诗的字段
+var poemFields = func() map[string]bool {
+	fields := make(map[string]bool)
+	t := reflect.TypeOf(Poem{})
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if len(name) != 0 && name != "-" {
+			fields[name] = true
+		}
+	}
+	return fields
+}()
+
+// checkPoem 检查必填的字段，不认识的字段只作为提醒，可能是新版本导出的
+func checkPoem(poem *Poem, raw json.RawMessage) (errs []*ImportError, warnings []*ImportError) {
+	if len(strings.TrimSpace(poem.Title)) == 0 {
+		errs = append(errs, &ImportError{Field: "title", Err: errors.New("标题为空")})
+	}
+	if len(strings.TrimSpace(poem.Content)) == 0 {
+		errs = append(errs, &ImportError{Field: "content", Err: errors.New("内容为空")})
+	}
+
+	fields := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(raw, &fields); err == nil {
+		names := make([]string, 0, len(fields))
+		for name := range fields {
+			if !poemFields[name] {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			warnings = append(warnings, &ImportError{Field: name, Err: errors.New("不认识的字段，将被忽略")})
+		}
+	}
+	return errs, warnings
+}
+
+// decodePoems 逐首解析base处开始的诗的数组，格式错误时停止，其他问题全部列出。
+// 不认识的字段和重复的序号不影响导入，放在warnings中
+func decodePoems(data []byte, base int64) (list []*Poem, errs ImportErrors, warnings ImportErrors) {
+	dec := json.NewDecoder(bytes.NewReader(data[base:]))
+	if tok, err := dec.Token(); err != nil {
+		return nil, ImportErrors{decodeError(data, 0, base, base, err)}, nil
+	} else if delim, ok := tok.(json.Delim); !ok || delim != '[' {
+		return nil, ImportErrors{{Line: lineAt(data, base+dec.InputOffset()), Err: errors.New("应为诗的数组")}}, nil
+	}
+
+	list = make([]*Poem, 0)
+	numbered := make(map[uint64]int)
+	for index := 1; dec.More(); index++ {
+		start := base + dec.InputOffset()
+		if start < int64(len(data)) && data[start] == ',' {
+			start++
+		}
+
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return nil, append(errs, decodeError(data, index, base, start, err)), warnings
+		}
+		start = skipSpace(data, start)
+
+		poem := &Poem{}
+		if err := json.Unmarshal(raw, poem); err != nil {
+			errs = append(errs, decodeError(data, index, start, start, err))
+			continue
+		}
+
+		poemErrs, poemWarnings := checkPoem(poem, raw)
+		for _, err := range poemErrs {
+			err.Index, err.Line = index, lineAt(data, start)
+			errs = append(errs, err)
+		}
+		for _, warning := range poemWarnings {
+			warning.Index, warning.Line = index, lineAt(data, start)
+			warnings = append(warnings, warning)
+		}
+		if first, ok := numbered[poem.No]; ok && poem.No != 0 {
+			warnings = append(warnings, &ImportError{Index: index, Line: lineAt(data, start), Field: "id", Err: fmt.Errorf("序号%d与第%d首诗重复", poem.No, first)})
+		} else {
+			numbered[poem.No] = index
+		}
+
+		list = append(list, poem)
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, append(errs, decodeError(data, 0, base, base, err)), warnings
+	}
+
+	return list, errs, warnings
+}
+
+// poemsOffset 导出文件中poems的值在文件中的位置，有多个poems时与json.Unmarshal一样取最后一个
+func poemsOffset(data []byte) (int64, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if _, err := dec.Token(); err != nil {
+		return 0, err
+	}
+
+	offset := int64(-1)
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return 0, err
+		}
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return 0, err
+		}
+		if key == "poems" {
+			offset = dec.InputOffset() - int64(len(raw))
+		}
+	}
+	if offset < 0 {
+		return 0, errors.New("没有诗")
+	}
+	return offset, nil
+}
+
+// ValidateExport 解析导出文件并列出所有问题，兼容旧版本不带头的数组。
+// errs不为空时不能导入，warnings只是提醒
+func ValidateExport(data []byte) (header *ExportHeader, list []*Poem, errs ImportErrors, warnings ImportErrors) {
+	base := skipSpace(data, 0)
+	if base < int64(len(data)) && data[base] == '[' {
+		list, errs, warnings = decodePoems(data, base)
+		return nil, list, errs, warnings
+	}
+
+	file := &exportFile{}
+	if err := json.Unmarshal(data, file); err != nil {
+		return nil, nil, ImportErrors{decodeError(data, 0, 0, 0, err)}, nil
+	}
+
+	header = &file.ExportHeader
+	switch {
+	case header.Format != exportFormat:
+		return header, nil, ImportErrors{{Field: "format", Err: errors.New("不是飞花令导出的文件")}}, nil
+	case header.Version > exportVersion:
+		return header, nil, ImportErrors{{Field: "version", Err: fmt.Errorf("文件版本%d过高，请升级app", header.Version)}}, nil
+	case len(file.Poems) == 0:
+		return header, nil, ImportErrors{{Field: "poems", Err: errors.New("没有诗")}}, nil
+	}
+
+	offset, err := poemsOffset(data)
+	if err != nil {
+		return header, nil, ImportErrors{{Field: "poems", Err: err}}, nil
+	}
+
+	errs = make(ImportErrors, 0)
+	if sum, err := checksum(file.Poems); err != nil || sum != header.Checksum {
+		errs = append(errs, &ImportError{Field: "checksum", Err: errors.New("校验和不符，文件可能被修改或不完整")})
+	}
+
+	list, poemErrs, warnings := decodePoems(data, offset)
+	errs = append(errs, poemErrs...)
+	if list != nil && len(list) != header.Count {
+		errs = append(errs, &ImportError{Field: "count", Err: fmt.Errorf("应有%d首诗，实际有%d首", header.Count, len(list))})
+	}
+
+	return header, list, errs, warnings
+}
+
+// readPoems 读取并检查导出的诗，没有错误才返回，不改动当前的诗。
+// 只有提醒时仍然返回诗，由调用者决定是否继续
+func readPoems(reader io.Reader) ([]*Poem, ImportErrors, error) {
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	_, list, errs, warnings := ValidateExport(data)
+	if len(errs) != 0 {
+		return nil, warnings, errs
+	}
+
+	for _, poem := range list {
+		poem.MakeSegments()
+	}
+	return list, warnings, nil
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// envelope 带头的导出文件，extra是放在poems之前的其他字段
+func envelope(t *testing.T, extra string, poems string) string {
+	t.Helper()
+	sum, err := checksum(json.RawMessage(poems))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var list []json.RawMessage
+	if err := json.Unmarshal([]byte(poems), &list); err != nil {
+		t.Fatal(err)
+	}
+	return fmt.Sprintf("{\n\"format\": %q,\n\"version\": %d,\n\"count\": %d,\n\"checksum\": %q,%s\n\"poems\": %s\n}",
+		exportFormat, exportVersion, len(list), sum, extra, poems)
+}
+
+func TestReadPoemsErrors(t *testing.T) {
+	const good = `{"id": 1, "title": "静夜思", "dynasty": "唐", "author": "李白", "content": "床前明月光，疑是地上霜。"}`
+	const noTitle = `{"id": 2, "title": "", "dynasty": "唐", "author": "李白", "content": "举头望明月，低头思故乡。"}`
+
+	tests := []struct {
+		name     string
+		data     string
+		errs     []string // 错误信息，包含位置
+		warnings []string
+		count    int
+	}{
+		{
+			name:  "legacy array",
+			data:  "[\n" + good + "\n]",
+			count: 1,
+		},
+		{
+			name: "syntax error",
+			data: "[\n" + good + ",\n{\"id\": 2,\n\"title\" \"x\"}\n]",
+			errs: []string{"第2首诗（第4行）：格式错误"},
+		},
+		{
+			name: "syntax error after blank lines",
+			data: "\n\n\n[\n" + good + ",\n{\"id\": 2\n\"title\": \"x\"}\n]",
+			errs: []string{"第2首诗（第7行）：格式错误"},
+		},
+		{
+			name: "type error",
+			data: "[\n" + good + ",\n{\"id\": \"2\",\n\"title\": \"x\", \"content\": \"y\"}\n]",
+			errs: []string{"第2首诗（第3行）id字段：应为uint64，实际为string"},
+		},
+		{
+			name: "truncated",
+			data: "[\n" + good + ",\n{\"id\": 2,",
+			errs: []string{"（第3行）：文件不完整"},
+		},
+		{
+			name: "missing title",
+			data: "[\n" + good + ",\n" + noTitle + "\n]",
+			errs: []string{"第2首诗（第3行）title字段：标题为空"},
+		},
+		{
+			name:     "unknown field and duplicate id",
+			data:     "[\n" + good + ",\n" + strings.Replace(good, `"id": 1,`, `"id": 1, "mood": "思乡",`, 1) + "\n]",
+			warnings: []string{"第2首诗（第3行）mood字段：不认识的字段，将被忽略", "第2首诗（第3行）id字段：序号1与第1首诗重复"},
+			count:    2,
+		},
+		{
+			name:  "envelope",
+			data:  envelope(t, "", "[\n"+good+"\n]"),
+			count: 1,
+		},
+		{
+			name: "envelope with same bytes before poems",
+			data: envelope(t, "\n\"copy\": [\n"+good+",\n"+noTitle+"\n],", "[\n"+good+",\n"+noTitle+"\n]"),
+			errs: []string{"第2首诗（第12行）title字段：标题为空"},
+		},
+		{
+			name: "newer version",
+			data: strings.Replace(envelope(t, "", "[\n"+good+"\n]"), fmt.Sprintf(`"version": %d`, exportVersion), `"version": 99`, 1),
+			errs: []string{"version字段：文件版本99过高，请升级app"},
+		},
+	}
+	for _, tt := range tests {
+		list, warnings, err := readPoems(strings.NewReader(tt.data))
+
+		var errs ImportErrors
+		if err != nil {
+			var ok bool
+			if errs, ok = err.(ImportErrors); !ok {
+				t.Errorf("%s: error %v is not ImportErrors", tt.name, err)
+				continue
+			}
+		}
+		if len(errs) != len(tt.errs) {
+			t.Errorf("%s: errors %q, want %q", tt.name, errs, tt.errs)
+		} else {
+			for i := range errs {
+				if errs[i].Error() != tt.errs[i] {
+					t.Errorf("%s: error %q, want %q", tt.name, errs[i].Error(), tt.errs[i])
+				}
+			}
+		}
+		if len(warnings) != len(tt.warnings) {
+			t.Errorf("%s: warnings %q, want %q", tt.name, warnings, tt.warnings)
+		} else {
+			for i := range warnings {
+				if warnings[i].Error() != tt.warnings[i] {
+					t.Errorf("%s: warning %q, want %q", tt.name, warnings[i].Error(), tt.warnings[i])
+				}
+			}
+		}
+		if len(list) != tt.count {
+			t.Errorf("%s: %d poems, want %d", tt.name, len(list), tt.count)
+		}
+	}
+}
+
+func TestPoemsOffset(t *testing.T) {
+	data := []byte(`{"copy": [1, 2], "poems": [1, 2], "count": 2}`)
+	offset, err := poemsOffset(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := int64(strings.LastIndex(string(data), "[1, 2]")); offset != want {
+		t.Errorf("poemsOffset = %d, want %d", offset, want)
+	}
+	if _, err := poemsOffset([]byte(`{"count": 0}`)); err == nil {
+		t.Error("poemsOffset without poems succeeded")
+	}
+}
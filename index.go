@@ -0,0 +1,170 @@
+package main
+
+import (
+	"unicode"
+)
+
+// contentIndex 诗句内容的倒排索引，以单字和相邻两字为键，不区分简繁
+type contentIndex struct {
+	postings map[string]map[*Poem]struct{}
+	keys     map[*Poem][]string // 每首诗登记过的键，删除时用
+}
+
+func newContentIndex() *contentIndex {
+	return &contentIndex{
+		postings: make(map[string]map[*Poem]struct{}),
+		keys:     make(map[*Poem][]string),
+	}
+}
+
+// indexable 只索引文字，标点和空白不参与
+func indexable(r rune) bool {
+	return !unicode.IsSpace(r) && !unicode.IsPunct(r)
+}
+
+// indexKeys 文本中的单字和相邻两字，去重
+func indexKeys(text string) []string {
+	runes := []rune(normalise(text))
+	found := make(map[string]bool)
+	keys := make([]string, 0, 2*len(runes))
+	add := func(key string) {
+		if !found[key] {
+			found[key] = true
+			keys = append(keys, key)
+		}
+	}
+
+	for i, r := range runes {
+		if !indexable(r) {
+			continue
+		}
+		add(string(r))
+		if i+1 < len(runes) && indexable(runes[i+1]) {
+			add(string(runes[i : i+2]))
+		}
+	}
+	return keys
+}
+
+func (idx *contentIndex) add(poem *Poem) {
+	if _, ok := idx.keys[poem]; ok {
+		return
+	}
+
+	keys := indexKeys(poem.Content)
+	for _, key := range keys {
+		set, ok := idx.postings[key]
+		if !ok {
+			set = make(map[*Poem]struct{})
+			idx.postings[key] = set
+		}
+		set[poem] = struct{}{}
+	}
+	idx.keys[poem] = keys
+}
+
+func (idx *contentIndex) remove(poem *Poem) {
+	for _, key := range idx.keys[poem] {
+		set := idx.postings[key]
+		delete(set, poem)
+		if len(set) == 0 {
+			delete(idx.postings, key)
+		}
+	}
+	delete(idx.keys, poem)
+}
+
+func (idx *contentIndex) rebuild(list []*Poem) {
+	idx.postings = make(map[string]map[*Poem]struct{})
+	idx.keys = make(map[*Poem][]string)
+	for _, poem := range list {
+		idx.add(poem)
+	}
+}
+
+// lookup 可能含有key的诗，key中有不能索引的字符时ok为false，需要逐首查找
+func (idx *contentIndex) lookup(key string) (map[*Poem]struct{}, bool) {
+	runes := []rune(normalise(key))
+	if len(runes) == 0 {
+		return nil, false
+	}
+	for _, r := range runes {
+		if !indexable(r) {
+			return nil, false
+		}
+	}
+
+	if len(runes) == 1 {
+		return idx.postings[string(runes)], true
+	}
+
+	// 所有相邻两字都出现的诗才可能含有key，从最少的开始求交集
+	var smallest map[*Poem]struct{}
+	sets := make([]map[*Poem]struct{}, 0, len(runes)-1)
+	for i := 0; i+1 < len(runes); i++ {
+		set := idx.postings[string(runes[i:i+2])]
+		if len(set) == 0 {
+			return nil, true
+		}
+		if smallest == nil || len(set) < len(smallest) {
+			smallest = set
+		}
+		sets = append(sets, set)
+	}
+
+	result := make(map[*Poem]struct{}, len(smallest))
+	for poem := range smallest {
+		matched := true
+		for _, set := range sets {
+			if _, ok := set[poem]; !ok {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			result[poem] = struct{}{}
+		}
+	}
+	return result, true
+}
+
+// candidates 按搜索中的内容关键字缩小范围，ok为false时需要查找全部的诗
+func (idx *contentIndex) candidates(s *Search) (map[*Poem]struct{}, bool) {
+	// 模糊搜索的诗不一定含有关键字
+	if s.Fuzzy {
+		return nil, false
+	}
+
+	keys := make([]string, 0, len(s.Content)+len(s.At))
+	for _, key := range s.Content {
+		// 拼音关键字不在索引中
+		if !isPinyinKey(key) {
+			keys = append(keys, key)
+		}
+	}
+	for _, at := range s.At {
+		keys = append(keys, at.Key)
+	}
+
+	var result map[*Poem]struct{}
+	narrowed := false
+	for _, key := range keys {
+		set, ok := idx.lookup(key)
+		if !ok {
+			continue
+		}
+		if !narrowed {
+			result, narrowed = set, true
+			continue
+		}
+
+		intersection := make(map[*Poem]struct{})
+		for poem := range result {
+			if _, ok := set[poem]; ok {
+				intersection[poem] = struct{}{}
+			}
+		}
+		result = intersection
+	}
+	return result, narrowed
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// scanFilter 不用索引，逐首查找
+func scanFilter(p *Poems, s *Search) []*Poem {
+	filtered := make([]*Poem, 0)
+	for _, poem := range p.list {
+		if poem.Matched(s) {
+			filtered = append(filtered, poem)
+		}
+	}
+	if s.Fuzzy {
+		sortByFuzzyCost(filtered, s.Content)
+	}
+	return filtered
+}
+
+// containsScan 内容含有所有关键字的诗，不区分简繁
+func containsScan(p *Poems, keys ...string) []*Poem {
+	filtered := make([]*Poem, 0)
+	for _, poem := range p.list {
+		matched := true
+		for _, key := range keys {
+			if !strings.Contains(normalise(poem.Content), normalise(key)) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			filtered = append(filtered, poem)
+		}
+	}
+	return filtered
+}
+
+func poemTitles(list []*Poem) string {
+	titles := make([]string, len(list))
+	for i, poem := range list {
+		titles[i] = poem.Title
+	}
+	return strings.Join(titles, ",")
+}
+
+func newIndexTestPoems() *Poems {
+	return newTestPoems(
+		NewPoem(0, "静夜思", "唐代", "李白", "床前明月光，\n疑是地上霜。\n举头望明月，\n低头思故乡。"),
+		NewPoem(0, "月下独酌", "唐代", "李白", "花间一壶酒，\n独酌无相亲。\n举杯邀明月，\n对影成三人。"),
+		NewPoem(0, "子夜吴歌", "唐代", "李白", "長安一片月，\n萬戶擣衣聲。\n秋風吹不盡，\n總是玉關情。"),
+		NewPoem(0, "春晓", "唐代", "孟浩然", "春眠不觉晓，\n处处闻啼鸟。\n夜来风雨声，\n花落知多少。"),
+		NewPoem(0, "春望", "唐代", "杜甫", "国破山河在，\n城春草木深。\n感时花溅泪，\n恨别鸟惊心。"),
+		NewPoem(0, "山居秋暝", "唐代", "王维", "空山新雨后，\n天气晚来秋。\n明月松间照，\n清泉石上流。"),
+	)
+}
+
+func TestFilterIndexMatchesContainsScan(t *testing.T) {
+	p := newIndexTestPoems()
+	tests := []struct {
+		name string
+		keys []string
+	}{
+		{"单字", []string{"月"}},
+		{"两字", []string{"明月"}},
+		{"三字", []string{"明月光"}},
+		{"两个关键字", []string{"花", "月"}},
+		{"繁体关键字", []string{"長安"}},
+		{"简体关键字找繁体诗", []string{"秋风"}},
+		{"不相邻的字", []string{"明光"}},
+		{"含标点", []string{"光，疑"}},
+		{"没有", []string{"鲸鱼"}},
+	}
+	for _, tt := range tests {
+		s := NewSearch(strings.Join(tt.keys, " "), false)
+		got, want := poemTitles(p.Filter(s)), poemTitles(containsScan(p, tt.keys...))
+		if got != want {
+			t.Errorf("%s %v: Filter = [%s], scan = [%s]", tt.name, tt.keys, got, want)
+		}
+	}
+}
+
+func TestFilterIndexMatchesScan(t *testing.T) {
+	p := newIndexTestPoems()
+	tests := []struct {
+		name  string
+		rule  string
+		fuzzy bool
+		want  string
+	}{
+		{"单字", "月", false, "静夜思,月下独酌,子夜吴歌,山居秋暝"},
+		{"两字", "明月", false, "静夜思,月下独酌,山居秋暝"},
+		{"繁体", "萬戶", false, "子夜吴歌"},
+		{"拼音", "mingyue", false, "静夜思,月下独酌,山居秋暝"},
+		{"拼音和汉字", "chun 花", false, "春晓,春望"},
+		{"模糊", "举头望名月", true, "静夜思"},
+		{"模糊单字", "月", true, "静夜思,月下独酌,子夜吴歌,山居秋暝"},
+		{"位置", "月@4", false, "静夜思"},
+		{"位置和关键字", "明@1 泉", false, "山居秋暝"},
+		{"繁体位置", "長@1", false, "子夜吴歌"},
+		{"或", "霜|泪", false, "静夜思,春望"},
+		{"排除", "月 -李白", false, "静夜思,月下独酌,子夜吴歌,山居秋暝"},
+		{"排除作者", "月 -a李白", false, "山居秋暝"},
+	}
+	for _, tt := range tests {
+		s := NewSearch(tt.rule, false)
+		s.Fuzzy = tt.fuzzy
+		got, scanned := poemTitles(p.Filter(s)), poemTitles(scanFilter(p, s))
+		if got != scanned {
+			t.Errorf("%s %q: Filter = [%s], scan = [%s]", tt.name, tt.rule, got, scanned)
+		}
+		if got != tt.want {
+			t.Errorf("%s %q: Filter = [%s], want [%s]", tt.name, tt.rule, got, tt.want)
+		}
+	}
+}
+
+// syntheticPoems 随机生成的诗库，用固定的种子
+func syntheticPoems(n int) *Poems {
+	chars := []rune("春江花月夜山水风云雨雪霜天地日星人家门前后来去归不见无有一千万里长安城中秋色明清白红青黄")
+	rnd := rand.New(rand.NewSource(1))
+	line := func(size int) string {
+		runes := make([]rune, size)
+		for i := range runes {
+			runes[i] = chars[rnd.Intn(len(chars))]
+		}
+		return string(runes)
+	}
+
+	list := make([]*Poem, n)
+	for i := range list {
+		size := 5 + 2*rnd.Intn(2)
+		content := fmt.Sprintf("%s，\n%s。\n%s，\n%s。", line(size), line(size), line(size), line(size))
+		list[i] = NewPoem(0, fmt.Sprintf("无题%d", i+1), "唐代", "佚名", content)
+	}
+	return newTestPoems(list...)
+}
+
+var benchmarkRules = []string{"月", "明月", "长安城", "春 花@1"}
+
+func BenchmarkFilterIndexed(b *testing.B) {
+	p := syntheticPoems(3000)
+	searches := make([]*Search, len(benchmarkRules))
+	for i, rule := range benchmarkRules {
+		searches[i] = NewSearch(rule, false)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, s := range searches {
+			p.Filter(s)
+		}
+	}
+}
+
+func BenchmarkFilterScan(b *testing.B) {
+	p := syntheticPoems(3000)
+	searches := make([]*Search, len(benchmarkRules))
+	for i, rule := range benchmarkRules {
+		searches[i] = NewSearch(rule, false)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, s := range searches {
+			scanFilter(p, s)
+		}
+	}
+}
+
+func TestSyntheticFilterMatchesScan(t *testing.T) {
+	p := syntheticPoems(500)
+	for _, rule := range benchmarkRules {
+		s := NewSearch(rule, false)
+		if got, want := len(p.Filter(s)), len(scanFilter(p, s)); got != want {
+			t.Errorf("%q: Filter found %d, scan found %d", rule, got, want)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"text/template"
+)
+
+// LineMatch 搜索结果中的一句诗
+type LineMatch struct {
+	Poem    *Poem
+	Segment *Segment
+}
+
+// MatchedLines 把搜到的诗展开成含有关键字的诗句，保持诗的顺序
+func MatchedLines(list []*Poem, s *Search) []*LineMatch {
+	lines := make([]*LineMatch, 0)
+	for _, poem := range list {
+		for _, seg := range poem.Segments {
+			if s.SegmentMatched(seg) {
+				lines = append(lines, &LineMatch{Poem: poem, Segment: seg})
+			}
+		}
+	}
+	return lines
+}
+
+// CountDistinctLines 不同诗句的数量，不区分简繁和标点，不同的诗中相同的句子只算一次
+func CountDistinctLines(lines []*LineMatch) int {
+	found := make(map[string]bool, len(lines))
+	for _, line := range lines {
+		found[normalise(stripPunctuation(line.Segment.Content))] = true
+	}
+	return len(found)
+}
+
+var lineMarkdownTpl = template.Must(template.New("line").Parse(`{{.MarkdownContent}}
+
+{{.Abstract}}`))
+
+// Markdown 高亮关键字的诗句，下面是诗的简介
+func (m *LineMatch) Markdown(s *Search) string {
+	content := m.Segment.Content
+	for _, key := range s.Highlights(m.Poem) {
+		content = highlight(content, key)
+	}
+
+	var buf bytes.Buffer
+	_ = lineMarkdownTpl.Execute(&buf, &PoemPreviewTemplateContext{Poem: m.Poem, MarkdownContent: content})
+	return display(buf.String())
+}
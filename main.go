@@ -8,13 +8,15 @@ import (
 	"fyne.io/fyne/v2/storage"
 )
 
-func loadPoems(dir fyne.URI) (*Poems, error) {
+func loadPoems(dir fyne.URI, profileId uint64) (*Poems, error) {
 	poems := NewPoems()
 	if path, err := storage.Child(dir, "poems.db"); err != nil {
 		return nil, err
 	} else {
 		if err := poems.Init(path.String()); err != nil {
 			return nil, err
+		} else if err := poems.SelectProfile(profileId); err != nil {
+			return nil, err
 		} else {
 			return poems, nil
 		}
@@ -25,13 +27,14 @@ func main() {
 	myApp := app.NewWithID("cn.poem.flower")
 	myWindow := myApp.NewWindow("飞花令")
 	myApp.Settings().SetTheme(&myTheme{})
+	SetDisplayScript(Script(myApp.Preferences().Int(scriptPreference)))
 	mgr := NewScreenManager()
 
 	if _, ok := myApp.(desktop.App); ok {
 		myWindow.Resize(fyne.NewSize(800, 600))
 	}
 
-	poems, err := loadPoems(myApp.Storage().RootURI())
+	poems, err := loadPoems(myApp.Storage().RootURI(), uint64(myApp.Preferences().Int(profilePreference)))
 	if err != nil {
 		panic(err.Error())
 	}
@@ -39,6 +42,13 @@ func main() {
 	mgr.Add("detail", NewDetailScreen(poems, mgr, myWindow))
 	mgr.Add("entry", NewEntryScreen(poems, mgr, myWindow))
 	mgr.Add("edit", NewEditScreen(poems, mgr, myWindow))
+	mgr.Add("bulk", NewBulkScreen(poems, mgr, myWindow))
+	mgr.Add("game", NewGameScreen(poems, mgr, myWindow))
+	mgr.Add("history", NewHistoryScreen(mgr, myWindow))
+	mgr.Add("review", NewReviewScreen(poems, mgr, myWindow))
+	mgr.Add("quiz", NewQuizScreen(poems, mgr, myWindow))
+	mgr.Add("drill", NewDrillScreen(poems, mgr, myWindow))
+	mgr.Add("stats", NewStatsScreen(poems, mgr, myWindow))
 
 	myWindow.SetContent(mgr.Build("entry"))
 	myWindow.ShowAndRun()
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"fmt"
+	"gorm.io/gorm"
+	"strings"
+)
+
+type ImportMode int
+
+const (
+	ImportReplace ImportMode = iota // 清空后导入
+	ImportMerge                     // 合并到现有的诗
+)
+
+var ImportModeNames = []string{"替换全部", "合并"}
+
+// ConflictPolicy 导入的诗与现有的诗不一致时的处理办法
+type ConflictPolicy int
+
+const (
+	ConflictKeep      ConflictPolicy = iota // 保留现有的诗
+	ConflictOverwrite                       // 用导入的诗覆盖
+)
+
+var ConflictPolicyNames = []string{"保留原有", "用导入的覆盖"}
+
+type ImportAction int
+
+const (
+	ImportAdd      ImportAction = iota // 新增
+	ImportUpdate                       // 覆盖现有的诗
+	ImportSkip                         // 与现有的诗相同
+	ImportConflict                     // 与现有的诗不一致，保留原有
+)
+
+var ImportActionNames = []string{"新增", "更新", "跳过", "冲突"}
+
+type ImportItem struct {
+	Poem     *Poem // 导入的诗
+	Existing *Poem // 对应的现有的诗，新增时为nil
+	Action   ImportAction
+}
+
+// ImportPlan 合并导入前的预览，确认后才写入
+type ImportPlan struct {
+	Items []*ImportItem
+}
+
+// poemKey 标题、作者和去掉标点的内容，不区分简繁
+func poemKey(poem *Poem) string {
+	return strings.Join([]string{
+		normalise(poem.Title),
+		normalise(poem.Author),
+		normalise(stripPunctuation(poem.Content)),
+	}, "\x00")
+}
+
+// samePoem 标题、朝代、作者、内容和附加信息都相同
+func samePoem(a, b *Poem) bool {
+	return poemKey(a) == poemKey(b) && normalise(a.Dynasty) == normalise(b.Dynasty) && sameInfo(a, b)
+}
+
+// sameTitleOrAuthor 按序号匹配时至少标题或作者相同，才认为是同一首诗改过的版本
+func sameTitleOrAuthor(a, b *Poem) bool {
+	return normalise(a.Title) == normalise(b.Title) || normalise(a.Author) == normalise(b.Author)
+}
+
+// PlanMerge 先按标题、作者和内容匹配现有的诗，再按序号匹配。
+// 序号相同但标题和作者都不同的是另一首诗，重新编号后新增
+func (p *Poems) PlanMerge(incoming []*Poem, policy ConflictPolicy) *ImportPlan {
+	byKey := make(map[string]*Poem, len(p.list))
+	byNo := make(map[uint64]*Poem, len(p.list))
+	usedNo := make(map[uint64]bool, len(p.list))
+	for _, poem := range p.list {
+		byKey[poemKey(poem)] = poem
+		byNo[poem.No] = poem
+		usedNo[poem.No] = true
+	}
+
+	// 文件中重复的诗只新增一次
+	added := make(map[string]*Poem)
+
+	next := p.NextNo()
+	plan := &ImportPlan{Items: make([]*ImportItem, 0, len(incoming))}
+	for _, poem := range incoming {
+		key := poemKey(poem)
+		item := &ImportItem{Poem: poem}
+
+		if existing, ok := byKey[key]; ok {
+			item.Existing = existing
+		} else if existing, ok := byNo[poem.No]; ok && poem.No != 0 && sameTitleOrAuthor(existing, poem) {
+			item.Existing = existing
+		} else if first, ok := added[key]; ok {
+			item.Existing = first
+			item.Action = ImportSkip
+			poem.No = first.No
+			plan.Items = append(plan.Items, item)
+			continue
+		}
+
+		switch {
+		case item.Existing == nil:
+			item.Action = ImportAdd
+			// 序号为空或与前面导入的诗重复时重新编号
+			if poem.No == 0 || usedNo[poem.No] {
+				poem.No = next
+			}
+			if poem.No >= next {
+				next = poem.No + 1
+			}
+			usedNo[poem.No] = true
+			added[key] = poem
+		case samePoem(item.Existing, poem):
+			item.Action = ImportSkip
+		case policy == ConflictOverwrite:
+			item.Action = ImportUpdate
+			poem.No = item.Existing.No
+		default:
+			item.Action = ImportConflict
+		}
+
+		plan.Items = append(plan.Items, item)
+	}
+
+	return plan
+}
+
+func (plan *ImportPlan) Count(action ImportAction) int {
+	n := 0
+	for _, item := range plan.Items {
+		if item.Action == action {
+			n++
+		}
+	}
+	return n
+}
+
+// Markdown 按处理办法分组列出导入的诗
+func (plan *ImportPlan) Markdown() string {
+	var b strings.Builder
+
+	counts := make([]string, 0, len(ImportActionNames))
+	for action, name := range ImportActionNames {
+		counts = append(counts, fmt.Sprintf("%s %d 首", name, plan.Count(ImportAction(action))))
+	}
+	b.WriteString(strings.Join(counts, "，"))
+	b.WriteString("\n\n")
+
+	for action, name := range ImportActionNames {
+		if plan.Count(ImportAction(action)) == 0 {
+			continue
+		}
+
+		fmt.Fprintf(&b, "## %s\n\n", name)
+		for _, item := range plan.Items {
+			if item.Action != ImportAction(action) {
+				continue
+			}
+			if item.Existing == nil || item.Action == ImportSkip {
+				fmt.Fprintf(&b, "- %s\n", item.Poem.Abstract())
+			} else {
+				fmt.Fprintf(&b, "- %s → %s\n", item.Poem.Abstract(), item.Existing.Abstract())
+			}
+		}
+		b.WriteString("\n")
+	}
+
+	return b.String()
+}
+
+// Merge 在一个事务中写入预览过的导入，覆盖时保留学习者的收藏和已背状态
+func (p *Poems) Merge(plan *ImportPlan) error {
+	err := transaction(func(tx *gorm.DB) error {
+		for _, item := range plan.Items {
+			switch item.Action {
+			case ImportAdd:
+				item.Poem.Memorised = true
+				if err := tx.Create(item.Poem).Error; err != nil {
+					tx.Rollback()
+					return err
+				}
+				if err := p.saveState(tx, item.Poem); err != nil {
+					tx.Rollback()
+					return err
+				}
+			case ImportUpdate:
+				if err := updatePoem(tx, item.Existing, item.Poem); err != nil {
+					tx.Rollback()
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, item := range plan.Items {
+		switch item.Action {
+		case ImportAdd:
+			p.list = append(p.list, item.Poem)
+			p.index.add(item.Poem)
+		case ImportUpdate:
+			p.replace(item.Poem)
+		}
+	}
+	return nil
+}
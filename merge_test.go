@@ -0,0 +1,79 @@
+package main
+
+import "testing"
+
+func TestPlanMerge(t *testing.T) {
+	existing := func() *Poems {
+		return newTestPoems(
+			NewPoem(1, "静夜思", "唐", "李白", "床前明月光，疑是地上霜。"),
+			NewPoem(5, "春晓", "唐", "孟浩然", "春眠不觉晓，处处闻啼鸟。"),
+		)
+	}
+
+	tests := []struct {
+		name     string
+		poem     *Poem
+		policy   ConflictPolicy
+		action   ImportAction
+		existing uint64 // 对应的现有的诗的序号，0表示没有
+		no       uint64 // 导入后的序号
+	}{
+		{"same poem", NewPoem(9, "静夜思", "唐", "李白", "床前明月光，疑是地上霜。"), ConflictKeep, ImportSkip, 1, 9},
+		{"traditional script", NewPoem(1, "靜夜思", "唐", "李白", "床前明月光，疑是地上霜。"), ConflictKeep, ImportSkip, 1, 1},
+		{"new poem", NewPoem(0, "登鹳雀楼", "唐", "王之涣", "白日依山尽，黄河入海流。"), ConflictKeep, ImportAdd, 0, 6},
+		{"new poem keeps free number", NewPoem(20, "登鹳雀楼", "唐", "王之涣", "白日依山尽，黄河入海流。"), ConflictKeep, ImportAdd, 0, 20},
+		{"edited content by number", NewPoem(5, "春晓", "唐", "孟浩然", "春眠不觉晓，处处闻啼鸟。夜来风雨声，花落知多少。"), ConflictKeep, ImportConflict, 5, 5},
+		{"edited content overwritten", NewPoem(5, "春晓", "唐", "孟浩然", "春眠不觉晓，处处闻啼鸟。夜来风雨声，花落知多少。"), ConflictOverwrite, ImportUpdate, 5, 5},
+		{"different dynasty", NewPoem(5, "春晓", "宋", "孟浩然", "春眠不觉晓，处处闻啼鸟。"), ConflictOverwrite, ImportUpdate, 5, 5},
+		{"unrelated poem with same number", NewPoem(5, "登鹳雀楼", "唐", "王之涣", "白日依山尽，黄河入海流。"), ConflictOverwrite, ImportAdd, 0, 6},
+		{"new tags", func() *Poem {
+			p := NewPoem(1, "静夜思", "唐", "李白", "床前明月光，疑是地上霜。")
+			p.Tags = []string{"课本"}
+			return p
+		}(), ConflictOverwrite, ImportUpdate, 1, 1},
+	}
+	for _, tt := range tests {
+		plan := existing().PlanMerge([]*Poem{tt.poem}, tt.policy)
+		item := plan.Items[0]
+		if item.Action != tt.action {
+			t.Errorf("%s: action %s, want %s", tt.name, ImportActionNames[item.Action], ImportActionNames[tt.action])
+		}
+		var no uint64
+		if item.Existing != nil {
+			no = item.Existing.No
+		}
+		if no != tt.existing {
+			t.Errorf("%s: existing #%d, want #%d", tt.name, no, tt.existing)
+		}
+		if item.Poem.No != tt.no {
+			t.Errorf("%s: imported as #%d, want #%d", tt.name, item.Poem.No, tt.no)
+		}
+	}
+}
+
+func TestPlanMergeDuplicates(t *testing.T) {
+	poems := newTestPoems(NewPoem(1, "静夜思", "唐", "李白", "床前明月光，疑是地上霜。"))
+	plan := poems.PlanMerge([]*Poem{
+		NewPoem(2, "春晓", "唐", "孟浩然", "春眠不觉晓，处处闻啼鸟。"),
+		NewPoem(2, "登鹳雀楼", "唐", "王之涣", "白日依山尽，黄河入海流。"),
+		NewPoem(7, "春晓", "唐", "孟浩然", "春眠不觉晓，处处闻啼鸟。"),
+	}, ConflictKeep)
+
+	want := []struct {
+		action ImportAction
+		no     uint64
+	}{
+		{ImportAdd, 2},
+		{ImportAdd, 3}, // 与前一首的序号重复，重新编号
+		{ImportSkip, 2},
+	}
+	for i, w := range want {
+		item := plan.Items[i]
+		if item.Action != w.action || item.Poem.No != w.no {
+			t.Errorf("item %d: %s #%d, want %s #%d", i, ImportActionNames[item.Action], item.Poem.No, ImportActionNames[w.action], w.no)
+		}
+	}
+	if n := plan.Count(ImportAdd); n != 2 {
+		t.Errorf("Count(ImportAdd) = %d, want 2", n)
+	}
+}
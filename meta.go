@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"text/template"
+	"unicode/utf8"
+)
+
+// Genres 常见的体裁，编辑时可以选，也可以自己填
+var Genres = []string{"五绝", "七绝", "五律", "七律", "古体诗", "乐府", "词", "曲", "赋"}
+
+// Annotation 一条注释，Word是被注释的词
+type Annotation struct {
+	Word string `json:"word,omitempty"`
+	Text string `json:"text"`
+}
+
+// ParseTags 用空格或逗号分开的标签，去掉重复的
+func ParseTags(text string) []string {
+	return ParseKeywords(text)
+}
+
+// ParseAnnotations 每行一条注释，格式为 词：解释，没有冒号时整行都是解释
+func ParseAnnotations(text string) []*Annotation {
+	annotations := make([]*Annotation, 0)
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		if i := strings.IndexAny(line, ":："); i >= 0 {
+			_, size := utf8.DecodeRuneInString(line[i:])
+			annotations = append(annotations, &Annotation{Word: strings.TrimSpace(line[:i]), Text: strings.TrimSpace(line[i+size:])})
+		} else {
+			annotations = append(annotations, &Annotation{Text: line})
+		}
+	}
+	return annotations
+}
+
+// FormatAnnotations 与ParseAnnotations相反，编辑时用
+func FormatAnnotations(annotations []*Annotation) string {
+	lines := make([]string, len(annotations))
+	for i, a := range annotations {
+		if len(a.Word) == 0 {
+			lines[i] = a.Text
+		} else {
+			lines[i] = a.Word + "：" + a.Text
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// HasTag 是否有这个标签，不区分简繁
+func (p *Poem) HasTag(tag string) bool {
+	tag = normalise(strings.TrimSpace(tag))
+	for _, t := range p.Tags {
+		if normalise(t) == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// notes 备注、译文和注释，搜索备注时用
+func (p *Poem) notes() []string {
+	texts := []string{p.Note, p.Translation}
+	for _, a := range p.Annotations {
+		texts = append(texts, a.Word, a.Text)
+	}
+	return texts
+}
+
+// sameInfo 体裁、标签、出处、备注、译文和注释都相同
+func sameInfo(a, b *Poem) bool {
+	if a.Genre != b.Genre || a.Source != b.Source || a.Note != b.Note || a.Translation != b.Translation {
+		return false
+	}
+	if strings.Join(a.Tags, "\x00") != strings.Join(b.Tags, "\x00") {
+		return false
+	}
+	return FormatAnnotations(a.Annotations) == FormatAnnotations(b.Annotations)
+}
+
+var poemInfoMarkdownTpl = template.Must(template.New("info").Funcs(template.FuncMap{
+	"join":       strings.Join,
+	"paragraphs": func(s string) string { return strings.ReplaceAll(strings.TrimSpace(s), "\n", "\n\n") },
+}).Parse(`{{with .Genre}}体裁：{{.}}
+
+{{end}}{{with .Tags}}标签：{{join . "、"}}
+
+{{end}}{{with .Source}}出处：{{.}}
+
+{{end}}{{with .Annotations}}## 注释
+
+{{range .}}{{if .Word}}**{{.Word}}**：{{end}}{{.Text}}
+
+{{end}}{{end}}{{with .Translation}}## 译文
+
+{{paragraphs .}}
+
+{{end}}{{with .Note}}## 备注
+
+{{paragraphs .}}
+{{end}}`))
+
+// InfoMarkdown 体裁、标签、出处、注释、译文和备注，都没有时为空
+func (p *Poem) InfoMarkdown() string {
+	var buf bytes.Buffer
+	_ = poemInfoMarkdownTpl.Execute(&buf, p)
+	return display(buf.String())
+}
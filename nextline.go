@@ -0,0 +1,102 @@
+package main
+
+import (
+	"math/rand"
+	"time"
+)
+
+// DrillQuestion 给出第Line句，回答第Answer句
+type DrillQuestion struct {
+	Poem   *Poem
+	Line   int
+	Answer int
+}
+
+func (q *DrillQuestion) Shown() *Segment {
+	return q.Poem.Segments[q.Line]
+}
+
+func (q *DrillQuestion) Expected() *Segment {
+	return q.Poem.Segments[q.Answer]
+}
+
+// IsNext 是否在问下一句
+func (q *DrillQuestion) IsNext() bool {
+	return q.Answer > q.Line
+}
+
+func (q *DrillQuestion) Check(input string) bool {
+	return normalise(stripPunctuation(input)) == normalise(stripPunctuation(q.Expected().Content))
+}
+
+// NewDrill 随机选一句，先问下一句再问上一句
+func NewDrill(poems []*Poem, r *rand.Rand) []*DrillQuestion {
+	candidates := make([]*Poem, 0, len(poems))
+	for _, poem := range poems {
+		if len(poem.Segments) > 1 {
+			candidates = append(candidates, poem)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	poem := candidates[r.Intn(len(candidates))]
+	line := r.Intn(len(poem.Segments))
+
+	questions := make([]*DrillQuestion, 0, 2)
+	if line+1 < len(poem.Segments) {
+		questions = append(questions, &DrillQuestion{Poem: poem, Line: line, Answer: line + 1})
+	}
+	if line > 0 {
+		questions = append(questions, &DrillQuestion{Poem: poem, Line: line, Answer: line - 1})
+	}
+
+	return questions
+}
+
+// DrillStat 学习者接下句的错误统计
+type DrillStat struct {
+	ProfileID uint64 `gorm:"primaryKey"`
+	PoemID    uint64 `gorm:"primaryKey"`
+	Attempts  int
+	Errors    int
+}
+
+// RecordDrill 记录一次接下句的结果，并计入这一句的复习计划
+func (p *Poems) RecordDrill(q *DrillQuestion, correct bool) error {
+	stat := &DrillStat{}
+	err := db.Where(map[string]interface{}{"profile_id": p.profile.ID, "poem_id": q.Poem.ID}).FirstOrInit(stat).Error
+	if err != nil {
+		return err
+	}
+
+	stat.Attempts++
+	grade := GradeGood
+	if !correct {
+		stat.Errors++
+		grade = GradeAgain
+	}
+
+	if err := db.Save(stat).Error; err != nil {
+		return err
+	}
+
+	return p.GradeLine(q.Poem, q.Answer+1, grade, time.Now())
+}
+
+// DrillStats 当前学习者出错最多的诗在前
+func (p *Poems) DrillStats() ([]*DrillStat, error) {
+	stats := make([]*DrillStat, 0)
+	err := db.Where("profile_id = ? AND errors > 0", p.profile.ID).Order("errors desc, attempts").Find(&stats).Error
+	return stats, err
+}
+
+func (p *Poems) FindByID(id uint64) *Poem {
+	for _, poem := range p.list {
+		if poem.ID == id {
+			return poem
+		}
+	}
+	return nil
+}
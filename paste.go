@@ -0,0 +1,90 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// authorLineRegexp 朝代和作者一行，例如 唐·李白、〔唐〕李白、唐代 李白
+var authorLineRegexp = regexp.MustCompile(`^[〔\[(（【]?(先秦|秦|两汉|汉|西汉|东汉|三国|魏晋|魏|晋|东晋|南北朝|南朝|北朝|隋|唐|五代|宋|北宋|南宋|辽|金|元|明|清|近现代|现代|当代)[代朝]?[〕\])）】]?\s*[·・•．.:：\-—\s]?\s*([^\s，。：？！,.:?!]{1,10})$`)
+
+// pasteMarkup 去掉Markdown的标记
+var pasteMarkup = strings.NewReplacer("**", "", "__", "", "`", "")
+
+// pasteHighlightRegexp 高亮时加粗的内容两边各有一个空格，去掉标记时一起去掉
+var pasteHighlightRegexp = regexp.MustCompile(` ?(\*\*|__)([^*_]+)(\*\*|__) ?`)
+
+func cleanPasteLine(line string) string {
+	line = pasteHighlightRegexp.ReplaceAllString(strings.TrimSpace(line), "$2")
+	line = strings.TrimLeft(line, ">-*+ ")
+	return strings.TrimSpace(pasteMarkup.Replace(line))
+}
+
+func parseAuthorLine(line string) (dynasty string, author string, ok bool) {
+	m := authorLineRegexp.FindStringSubmatch(line)
+	if m == nil {
+		return "", "", false
+	}
+
+	dynasty = m[1]
+	// 和自带的诗一样写成唐代、宋代
+	if len([]rune(dynasty)) == 1 && strings.Contains("秦汉隋唐宋辽金元明清", dynasty) {
+		dynasty += "代"
+	}
+	return dynasty, m[2], true
+}
+
+// ParsePaste 把粘贴的多首诗拆开，支持 标题/朝代·作者/正文 的纯文本，以及DetailMarkdown那样的Markdown
+func ParsePaste(text string) []*Poem {
+	lines := make([]string, 0)
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		if line = strings.TrimSpace(line); len(line) != 0 {
+			lines = append(lines, line)
+		}
+	}
+
+	// 标题是以#开头的行，或者下一行是朝代和作者的没有标点的行
+	isTitle := func(i int) bool {
+		if strings.HasPrefix(lines[i], "#") {
+			return true
+		}
+		if i+1 >= len(lines) || strings.ContainsAny(lines[i], segmentPunctuations) {
+			return false
+		}
+		_, _, ok := parseAuthorLine(cleanPasteLine(lines[i+1]))
+		return ok
+	}
+
+	list := make([]*Poem, 0)
+	var poem *Poem
+	var body []string
+	finish := func() {
+		if poem != nil {
+			poem.Content = breakLines(strings.Join(body, "\n"))
+			poem.MakeSegments()
+			list = append(list, poem)
+		}
+	}
+
+	for i := 0; i < len(lines); i++ {
+		if !isTitle(i) {
+			if poem == nil { // 没有标题时整段算作一首诗
+				poem = &Poem{}
+			}
+			body = append(body, cleanPasteLine(lines[i]))
+			continue
+		}
+
+		finish()
+		poem, body = &Poem{Title: cleanPasteLine(strings.TrimLeft(lines[i], "#"))}, nil
+		if i+1 < len(lines) {
+			if dynasty, author, ok := parseAuthorLine(cleanPasteLine(lines[i+1])); ok {
+				poem.Dynasty, poem.Author = dynasty, author
+				i++
+			}
+		}
+	}
+	finish()
+
+	return list
+}
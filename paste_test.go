@@ -0,0 +1,31 @@
+package main
+
+import "testing"
+
+func TestCleanPasteLine(t *testing.T) {
+	tests := []struct {
+		line, want string
+	}{
+		{"床前明月光，疑是地上霜。", "床前明月光，疑是地上霜。"},
+		{"床前 **明月** 光，疑是地上霜。", "床前明月光，疑是地上霜。"},
+		{" **床前明月光，** 疑是地上霜。", "床前明月光，疑是地上霜。"},
+		{"床前明月光，疑是地上 **霜** ", "床前明月光，疑是地上霜"},
+		{"> **唐代** ·李白", "唐代·李白"},
+		{"- `静夜思`", "静夜思"},
+	}
+	for _, tt := range tests {
+		if got := cleanPasteLine(tt.line); got != tt.want {
+			t.Errorf("cleanPasteLine(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestParsePasteHighlighted(t *testing.T) {
+	list := ParsePaste("# 静夜思\n\n唐代·李白\n\n床前 **明月** 光，疑是地上霜。\n举头望 **明月** ，低头思故乡。\n")
+	if len(list) != 1 {
+		t.Fatalf("got %d poems, want 1", len(list))
+	}
+	if want := "床前明月光，\n疑是地上霜。\n举头望明月，\n低头思故乡。"; list[0].Content != want {
+		t.Errorf("content = %q, want %q", list[0].Content, want)
+	}
+}
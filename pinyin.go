@@ -0,0 +1,210 @@
+package main
+
+import (
+	"github.com/mozillazg/go-pinyin"
+	"strings"
+	"unicode"
+)
+
+var pinyinArgs = func() pinyin.Args {
+	args := pinyin.NewArgs()
+	args.Style = pinyin.Tone
+	args.Heteronym = false
+	return args
+}()
+
+// pinyinContext 多音字按前后的字确定读音，Prev和Next为空时不限，
+// LineEnd表示这个字在句末，也就是押韵的位置
+type pinyinContext struct {
+	Prev    string
+	Next    string
+	LineEnd bool
+	Reading string
+}
+
+// contextPinyin 诗词中常见的多音字，按顺序取第一条符合的规则，都不符合时用字典的读音
+var contextPinyin = map[rune][]pinyinContext{
+	'长': {
+		{Prev: "生成增滋助家首兄师尊年", Reading: "zhǎng"},
+		{Next: "大老辈者官幼", Reading: "zhǎng"},
+		{Reading: "cháng"},
+	},
+	'还': {
+		{Next: "是有要在似应将能", Reading: "hái"},
+		{Reading: "huán"},
+	},
+	'看': {
+		{Next: "守护管家门", Reading: "kān"},
+		{LineEnd: true, Reading: "kān"}, // 闺中只独看
+	},
+	'尽': {
+		{Next: "管量早快", Reading: "jǐn"},
+		{Reading: "jìn"},
+	},
+	'似': {
+		{Next: "的", Reading: "shì"},
+		{Reading: "sì"},
+	},
+	'子': {
+		{Prev: "游君公天孔孟庄童弟女男学才赤莲孙妻稚士舟", Reading: "zǐ"},
+	},
+	'了': {
+		{Prev: "未何时不", Reading: "liǎo"},
+		{Next: "却无然解得", Reading: "liǎo"},
+	},
+	'干': {
+		{Prev: "阑栏相江河若", Reading: "gān"},
+		{Next: "戈涸净枯云霄", Reading: "gān"},
+	},
+}
+
+// contextReading 按contextPinyin确定第i个字的读音
+func contextReading(runes []rune, i int) (string, bool) {
+	rules, ok := contextPinyin[runes[i]]
+	if !ok {
+		return "", false
+	}
+
+	var prev, next rune
+	if i > 0 {
+		prev = runes[i-1]
+	}
+	if i+1 < len(runes) {
+		next = runes[i+1]
+	}
+	lineEnd := !unicode.Is(unicode.Han, next)
+
+	for _, rule := range rules {
+		if len(rule.Prev) != 0 && !strings.ContainsRune(rule.Prev, prev) {
+			continue
+		}
+		if len(rule.Next) != 0 && !strings.ContainsRune(rule.Next, next) {
+			continue
+		}
+		if rule.LineEnd && !lineEnd {
+			continue
+		}
+		return rule.Reading, true
+	}
+	return "", false
+}
+
+// phrasePinyin 按词语确定多音字的读音，优先于单字
+var phrasePinyin = map[string]string{
+	"还是":  "hái shì",
+	"还有":  "hái yǒu",
+	"一骑":  "yí jì",
+	"鬓毛衰": "bìn máo cuī",
+	"少小":  "shào xiǎo",
+	"少年":  "shào nián",
+	"白发":  "bái fà",
+	"华发":  "huá fà",
+	"鬓发":  "bìn fà",
+	"朝辞":  "zhāo cí",
+	"朝如":  "zhāo rú",
+	"朝雨":  "zhāo yǔ",
+	"朝朝":  "zhāo zhāo",
+	"朝阳":  "zhāo yáng",
+	"今朝":  "jīn zhāo",
+	"明朝":  "míng zhāo",
+	"万重":  "wàn chóng",
+	"九重":  "jiǔ chóng",
+	"重重":  "chóng chóng",
+	"重阳":  "chóng yáng",
+	"重来":  "chóng lái",
+	"重见":  "chóng jiàn",
+	"一曲":  "yì qǔ",
+	"曲终":  "qǔ zhōng",
+	"一行":  "yì háng",
+	"两行":  "liǎng háng",
+	"没在":  "mò zài",
+	"没石":  "mò shí",
+}
+
+const maxPhraseLen = 3
+
+// Pinyin 逐字注音，不是汉字的位置为空
+func Pinyin(text string) []string {
+	runes := []rune(text)
+	result := make([]string, len(runes))
+
+	for i := 0; i < len(runes); {
+		matched := false
+		for n := maxPhraseLen; n >= 2; n-- {
+			if i+n > len(runes) {
+				continue
+			}
+			if py, ok := phrasePinyin[string(runes[i:i+n])]; ok {
+				copy(result[i:i+n], strings.Fields(py))
+				i += n
+				matched = true
+				break
+			}
+		}
+		if matched {
+			continue
+		}
+
+		c := runes[i]
+		if py, ok := contextReading(runes, i); ok {
+			result[i] = py
+		} else if unicode.Is(unicode.Han, c) {
+			if py := pinyin.SinglePinyin(c, pinyinArgs); len(py) > 0 {
+				result[i] = py[0]
+			}
+		}
+		i++
+	}
+
+	return result
+}
+
+var tonelessReplacer = strings.NewReplacer(
+	"ā", "a", "á", "a", "ǎ", "a", "à", "a",
+	"ē", "e", "é", "e", "ě", "e", "è", "e",
+	"ī", "i", "í", "i", "ǐ", "i", "ì", "i",
+	"ō", "o", "ó", "o", "ǒ", "o", "ò", "o",
+	"ū", "u", "ú", "u", "ǔ", "u", "ù", "u",
+	"ǖ", "v", "ǘ", "v", "ǚ", "v", "ǜ", "v", "ü", "v",
+	"ń", "n", "ň", "n", "ǹ", "n", "ḿ", "m",
+)
+
+// toneless 去掉声调，ü写作v
+func toneless(py string) string {
+	return tonelessReplacer.Replace(py)
+}
+
+// isPinyinKey 关键字是否为拼音或拼音首字母，不区分大小写
+func isPinyinKey(key string) bool {
+	if len(key) == 0 {
+		return false
+	}
+	for _, c := range strings.ToLower(key) {
+		if c < 'a' || c > 'z' {
+			return false
+		}
+	}
+	return true
+}
+
+// pinyinFind 在text中查找全拼或首字母为key的文字，不区分大小写
+func pinyinFind(text []rune, py []string, key string) (string, bool) {
+	key = strings.ToLower(key)
+	for i := range text {
+		full, initials := "", ""
+		for j := i; j < len(text) && len(py[j]) != 0; j++ {
+			syllable := toneless(py[j])
+			full += syllable
+			initials += syllable[:1]
+
+			if full == key || (len(key) > 1 && initials == key) {
+				return string(text[i : j+1]), true
+			}
+			if !strings.HasPrefix(key, full) && !strings.HasPrefix(key, initials) {
+				break
+			}
+		}
+	}
+
+	return "", false
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPinyin(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"床前明月光", "chuáng qián míng yuè guāng"},
+		{"长安一片月", "cháng ān yī piàn yuè"},
+		{"孩子长大了", "hái zi zhǎng dà le"},
+		{"春风又绿江南岸，明月何时照我还", "chūn fēng yòu lǜ jiāng nán àn  míng yuè hé shí zhào wǒ huán"},
+		{"还是", "hái shì"},
+		{"远上寒山石径斜", "yuǎn shàng hán shān shí jìng xié"},
+		{"遥看瀑布挂前川", "yáo kàn pù bù guà qián chuān"},
+		{"闺中只独看。", "guī zhōng zhǐ dú kān "},
+		{"了却君王天下事", "liǎo què jūn wáng tiān xià shì"},
+		{"春花秋月何时了", "chūn huā qiū yuè hé shí liǎo"},
+		{"游子身上衣", "yóu zǐ shēn shàng yī"},
+		{"桃花潭水深千尺", "táo huā tán shuǐ shēn qiān chǐ"},
+		{"独倚阑干", "dú yǐ lán gān"},
+		{"天似穹庐", "tiān sì qióng lú"},
+		{"一骑红尘妃子笑", "yí jì hóng chén fēi zi xiào"},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(Pinyin(tt.text), " "); got != tt.want {
+			t.Errorf("Pinyin(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestIsPinyinKey(t *testing.T) {
+	tests := []struct {
+		key  string
+		want bool
+	}{
+		{"hua", true},
+		{"Hua", true},
+		{"CQMH", true},
+		{"", false},
+		{"花", false},
+		{"hua1", false},
+		{"ming yue", false},
+	}
+	for _, tt := range tests {
+		if got := isPinyinKey(tt.key); got != tt.want {
+			t.Errorf("isPinyinKey(%q) = %v, want %v", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestPinyinFind(t *testing.T) {
+	seg := NewPoem(1, "静夜思", "唐", "李白", "床前明月光，").Segments[0]
+	tests := []struct {
+		key  string
+		want string
+		ok   bool
+	}{
+		{"mingyue", "明月", true},
+		{"MingYue", "明月", true},
+		{"cqmyg", "床前明月光", true},
+		{"CQMH", "", false},
+		{"m", "", false}, // 单个首字母不算
+		{"guang", "光", true},
+	}
+	for _, tt := range tests {
+		got, ok := seg.FindPinyin(tt.key)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("FindPinyin(%q) = %q, %v, want %q, %v", tt.key, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -2,30 +2,40 @@ package main
 
 import (
 	"bytes"
-	_ "embed"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"fyne.io/fyne/v2"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
-	"io/ioutil"
 	"os"
 	"path/filepath"
 	"regexp"
 	"strings"
 	"text/template"
+	"time"
+	"unicode"
 )
 
 type Poem struct {
-	ID       uint64     `json:"-" gorm:"primarykey"`
-	No       uint64     `json:"id"`
-	Title    string     `json:"title"`
-	Dynasty  string     `json:"dynasty"`
-	Author   string     `json:"author"`
-	Content  string     `json:"content"`
-	Favor    bool       `json:"favor"`
-	Segments []*Segment `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	ID          uint64         `json:"-" gorm:"primarykey"`
+	No          uint64         `json:"id"`
+	Title       string         `json:"title"`
+	Dynasty     string         `json:"dynasty"`
+	Author      string         `json:"author"`
+	Content     string         `json:"content"`
+	Genre       string         `json:"genre,omitempty"`                              // 体裁，例如五绝、词
+	Tags        []string       `json:"tags,omitempty" gorm:"serializer:json"`        // 标签，例如课本、送别
+	Source      string         `json:"source,omitempty"`                             // 出处，例如诗集或课本
+	Note        string         `json:"note,omitempty"`                               // 备注
+	Translation string         `json:"translation,omitempty"`                        // 译文
+	Annotations []*Annotation  `json:"annotations,omitempty" gorm:"serializer:json"` // 注释
+	Favor       bool           `json:"favor" gorm:"-"`                               // 当前学习者是否收藏
+	Memorised   bool           `json:"-" gorm:"-"`                                   // 当前学习者是否已背
+	Viewed      time.Time      `json:"-" gorm:"-"`                                   // 当前学习者最近一次查看的时间
+	Segments    []*Segment     `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Profiles    []*ProfilePoem `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Cards       []*ReviewCard  `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Drills      []*DrillStat   `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
 }
 
 func NewPoem(no uint64, title string, dynasty string, author string, content string) *Poem {
@@ -36,12 +46,45 @@ func NewPoem(no uint64, title string, dynasty string, author string, content str
 
 type Segment struct {
 	ID      uint64 `gorm:"primarykey"`
+	Seq     int    // 在诗中的顺序，从0开始
 	Content string
 	PoemID  uint64
+
+	pinyin []string
+}
+
+// Pinyin 逐字注音，第一次使用时生成
+func (s *Segment) Pinyin() []string {
+	if s.pinyin == nil {
+		s.pinyin = Pinyin(normalise(s.Content))
+	}
+	return s.pinyin
+}
+
+// FindPinyin 在分句中查找读音为key的文字
+func (s *Segment) FindPinyin(key string) (string, bool) {
+	return pinyinFind([]rune(s.Content), s.Pinyin(), key)
+}
+
+// Text 去掉分句时保留的标点
+func (s *Segment) Text() string {
+	return strings.TrimRight(s.Content, segmentPunctuations)
+}
+
+const segmentPunctuations = "，。：？！,.:?!"
+
+// stripPunctuation 去掉用户输入中的标点和空白
+func stripPunctuation(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) || unicode.IsPunct(r) {
+			return -1
+		}
+		return r
+	}, s)
 }
 
 func (p *Poem) Abstract() string {
-	return fmt.Sprintf("%d. %s  (%s %s)", p.No, p.Title, p.Dynasty, p.Author)
+	return display(fmt.Sprintf("%d. %s  (%s %s)", p.No, p.Title, p.Dynasty, p.Author))
 }
 
 func (p *Poem) MakeSegments() {
@@ -49,31 +92,113 @@ func (p *Poem) MakeSegments() {
 	r := regexp.MustCompile(`.*?[，。：？！,.:?!]`)
 	segments := r.FindAllString(content, -1)
 	p.Segments = make([]*Segment, 0, len(segments))
-	for _, seg := range segments {
+	for i, seg := range segments {
 		p.Segments = append(p.Segments, &Segment{
+			Seq:     i,
 			Content: seg,
 			PoemID:  p.ID,
 		})
 	}
 }
 
+// Convert 把诗转换成简体或繁体，并重新分句
+func (p *Poem) Convert(s Script) {
+	p.Title = s.Convert(p.Title)
+	p.Dynasty = s.Convert(p.Dynasty)
+	p.Author = s.Convert(p.Author)
+	p.Content = s.Convert(p.Content)
+	p.MakeSegments()
+}
+
+var lineRegexp = regexp.MustCompile(`.*?[，。：？！,.:?!]|.+$`)
+
+// breakLines 每句一行，与自带的诗一致，原有的换行保留
+func breakLines(content string) string {
+	lines := make([]string, 0)
+	for _, line := range strings.Split(content, "\n") {
+		for _, l := range lineRegexp.FindAllString(line, -1) {
+			if l = strings.TrimSpace(l); len(l) != 0 {
+				lines = append(lines, l)
+			}
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
 func highlight(s, key string) string {
 	return strings.ReplaceAll(s, key, fmt.Sprintf(" **%s** ", key))
 }
 
+// segmentsMarkdown 把分句重新拼成Markdown，句号、问号、叹号后分段
+func segmentsMarkdown(segments []*Segment, render func(i int, seg *Segment) string) string {
+	var b strings.Builder
+	for i, seg := range segments {
+		b.WriteString(render(i, seg))
+		if strings.ContainsAny(seg.Content, "。？！.?!") {
+			b.WriteString("\n\n")
+		}
+	}
+	return b.String()
+}
+
 type PoemDetailTemplateContext struct {
 	*Poem
 	MarkdownContent string
+	Marked          string // 要突出显示的诗句
 }
 
-func NewPoemDetailTemplateContext(poem *Poem, s *Search) *PoemDetailTemplateContext {
-	content := strings.ReplaceAll(poem.Content, "\n", "\n\n")
+// segmentRange 分句在内容中的位置，按字计算，内容中的换行不在分句中
+func (p *Poem) segmentRange(line *Segment) (int, int, bool) {
+	start, found := 0, false
+	for _, seg := range p.Segments {
+		if seg.Seq == line.Seq {
+			found = true
+			break
+		}
+		start += len([]rune(seg.Content))
+	}
+	if !found {
+		return 0, 0, false
+	}
+	end := start + len([]rune(line.Content))
 
-	for _, key := range s.Content {
-		content = highlight(content, key)
+	from, n := -1, 0
+	for i, r := range []rune(p.Content) {
+		if r == '\n' {
+			continue
+		}
+		if n == start {
+			from = i
+		}
+		n++
+		if n == end && from >= 0 {
+			return from, i + 1, true
+		}
 	}
+	return 0, 0, false
+}
 
-	return &PoemDetailTemplateContext{Poem: poem, MarkdownContent: content}
+// NewPoemDetailTemplateContext line不为nil时整句加粗，其余的部分高亮关键字
+func NewPoemDetailTemplateContext(poem *Poem, s *Search, line *Segment) *PoemDetailTemplateContext {
+	highlighted := func(content string) string {
+		for _, key := range s.Highlights(poem) {
+			content = highlight(content, key)
+		}
+		return strings.ReplaceAll(content, "\n", "\n\n")
+	}
+
+	if line != nil {
+		if from, to, ok := poem.segmentRange(line); ok {
+			runes := []rune(poem.Content)
+			marked := string(runes[from:to])
+			if !strings.Contains(marked, "\n") {
+				content := highlighted(string(runes[:from])) + fmt.Sprintf(" **%s** ", marked) + highlighted(string(runes[to:]))
+				return &PoemDetailTemplateContext{Poem: poem, MarkdownContent: content, Marked: marked}
+			}
+		}
+	}
+
+	return &PoemDetailTemplateContext{Poem: poem, MarkdownContent: highlighted(poem.Content)}
 }
 
 var poemDetailMarkdownTpl *template.Template
@@ -88,24 +213,15 @@ func NewPoemPreviewTemplateContext(poem *Poem, s *Search) *PoemPreviewTemplateCo
 
 	segments := make([]string, 0, MaxSegment)
 
-	matched := func(seg string) bool {
-		for _, key := range s.Content {
-			if strings.Contains(seg, key) {
-				return true
-			}
-		}
-		return false
-	}
-
 	highlighted := func(seg string) string {
-		for _, key := range s.Content {
+		for _, key := range s.Highlights(poem) {
 			seg = highlight(seg, key)
 		}
 		return seg
 	}
 
 	for _, seg := range poem.Segments {
-		if matched(seg.Content) {
+		if s.SegmentMatched(seg) {
 			segments = append(segments, highlighted(seg.Content))
 			if len(segments) == MaxSegment {
 				break
@@ -134,78 +250,98 @@ func init() {
 var db *gorm.DB
 
 func (p *Poem) DetailMarkdown(s *Search) string {
+	return NewPoemDetailTemplateContext(p, s, nil).Markdown()
+}
+
+func (c *PoemDetailTemplateContext) Markdown() string {
 	var buf bytes.Buffer
-	_ = poemDetailMarkdownTpl.Execute(&buf, NewPoemDetailTemplateContext(p, s))
+	_ = poemDetailMarkdownTpl.Execute(&buf, c)
 
-	return buf.String()
+	return display(buf.String())
 }
 
 func (p *Poem) PreviewMarkdown(s *Search) string {
 	var buf bytes.Buffer
 	_ = poemPreviewMarkdownTpl.Execute(&buf, NewPoemPreviewTemplateContext(p, s))
 
-	return buf.String()
+	return display(buf.String())
 }
 
-func (p *Poem) Matched(s *Search) bool {
-	if s.No != 0 {
-		if p.No != s.No {
-			return false
-		}
+// Contains 内容含有关键字，不区分简繁，关键字也可以是拼音或拼音首字母
+func (p *Poem) Contains(key string) bool {
+	if strings.Contains(normalise(p.Content), normalise(key)) {
+		return true
 	}
 
-	if s.FavorOnly {
-		if !p.Favor {
-			return false
+	if isPinyinKey(key) {
+		for _, seg := range p.Segments {
+			if _, ok := seg.FindPinyin(key); ok {
+				return true
+			}
 		}
 	}
 
-	if len(s.Title) != 0 {
-		if !strings.Contains(p.Title, s.Title) {
+	return false
+}
+
+func (p *Poem) Matched(s *Search) bool {
+	if s.FavorOnly {
+		if !p.Favor {
 			return false
 		}
 	}
 
-	if len(s.Dynasty) != 0 {
-		if !strings.Contains(p.Dynasty, s.Dynasty) {
+	if s.MemorisedOnly {
+		if !p.Memorised {
 			return false
 		}
 	}
 
-	if len(s.Author) != 0 {
-		if !strings.Contains(p.Author, s.Author) {
-			return false
-		}
+	if !s.termsMatched(p) {
+		return false
 	}
 
 	if len(s.Content) != 0 {
 		for _, key := range s.Content {
-			if !strings.Contains(p.Content, key) {
+			if !p.Contains(key) && !(s.Fuzzy && p.fuzzyContains(key)) {
 				return false
 			}
 		}
 	}
 
+	for _, at := range s.At {
+		found := false
+		for _, seg := range p.Segments {
+			if keywordAt(seg.Text(), at.Key, at.Pos) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
 	return true
 }
 
 type Poems struct {
-	list []*Poem
+	list    []*Poem
+	profile *Profile
+	index   *contentIndex
 }
 
 func NewPoems() *Poems {
 	return &Poems{
-		list: make([]*Poem, 0),
+		list:  make([]*Poem, 0),
+		index: newContentIndex(),
 	}
 }
 
-//go:embed poems.json
-var _defaultPoems []byte
-
-func (p *Poems) MakeSegments() {
-	for _, poem := range p.list {
-		poem.MakeSegments()
-	}
+// setList 替换全部的诗并重建索引
+func (p *Poems) setList(list []*Poem) {
+	p.list = list
+	p.index.rebuild(list)
 }
 
 func toFilePath(uri string) (string, error) {
@@ -268,7 +404,7 @@ func (p *Poems) Init(uri string) error {
 		return err
 	}
 
-	err = db.AutoMigrate(&Poem{}, &Segment{})
+	err = db.AutoMigrate(&Poem{}, &Segment{}, &Profile{}, &ProfilePoem{}, &ReviewCard{}, &DrillStat{}, &GameRecord{}, &GameRecordPlayer{}, &GameRecordLine{})
 	if err != nil {
 		return err
 	}
@@ -277,74 +413,139 @@ func (p *Poems) Init(uri string) error {
 		return err
 	}
 
-	err = db.Model(&Poem{}).Preload("Segments").Find(&p.list).Error
+	err = migrateProfiles()
 	if err != nil {
 		return err
 	}
 
-	return nil
-}
+	err = db.Model(&Poem{}).Preload("Segments", func(tx *gorm.DB) *gorm.DB {
+		return tx.Order("seq, id")
+	}).Find(&p.list).Error
+	if err != nil {
+		return err
+	}
 
-func (p *Poems) LoadDefault() {
-	_ = json.Unmarshal(_defaultPoems, &p.list)
-	p.MakeSegments()
+	err = p.migrateSegmentSeq()
+	if err != nil {
+		return err
+	}
+	p.index.rebuild(p.list)
+
+	return p.SelectProfile(0)
 }
 
-func (p *Poems) Load(reader fyne.URIReadCloser) (err error) {
-	defer func(reader fyne.URIReadCloser) {
-		err = reader.Close()
-	}(reader)
+// migrateSegmentSeq 旧版本没有保存分句的顺序，按ID补上
+func (p *Poems) migrateSegmentSeq() error {
+	for _, poem := range p.list {
+		legacy := len(poem.Segments) > 1
+		for _, seg := range poem.Segments {
+			if seg.Seq != 0 {
+				legacy = false
+				break
+			}
+		}
+		if !legacy {
+			continue
+		}
 
-	if data, err := ioutil.ReadAll(reader); err != nil {
-		return err
-	} else {
-		if err := json.Unmarshal(data, &p.list); err != nil {
-			return err
+		for i, seg := range poem.Segments {
+			if err := db.Model(seg).Update("seq", i).Error; err != nil {
+				return err
+			}
 		}
-		p.MakeSegments()
-		return nil
 	}
+
+	return nil
 }
 
-func (p *Poems) Clear() error {
-	if err := db.Session(&gorm.Session{AllowGlobalUpdate: true}).Delete(&Poem{}).Error; err != nil {
-		return err
+// matchReplace 替换全部的诗时，导入的诗中与现有的诗标题、作者和内容相同的对应起来，
+// 返回对应关系和要删除的现有的诗
+func (p *Poems) matchReplace(list []*Poem) (map[*Poem]*Poem, []*Poem) {
+	byKey := make(map[string]*Poem, len(p.list))
+	for _, poem := range p.list {
+		if _, ok := byKey[poemKey(poem)]; !ok {
+			byKey[poemKey(poem)] = poem
+		}
 	}
 
-	p.list = make([]*Poem, 0)
-	return nil
+	matched := make(map[*Poem]*Poem)
+	kept := make(map[*Poem]bool)
+	for _, poem := range list {
+		if existing, ok := byKey[poemKey(poem)]; ok && !kept[existing] {
+			matched[poem] = existing
+			kept[existing] = true
+		}
+	}
+
+	removed := make([]*Poem, 0)
+	for _, poem := range p.list {
+		if !kept[poem] {
+			removed = append(removed, poem)
+		}
+	}
+	return matched, removed
 }
 
-func (p *Poems) createAll() error {
-	return transaction(func(tx *gorm.DB) error {
-		for _, poem := range p.list {
-			if err := db.Create(poem).Error; err != nil {
+// ReplaceRemoved 替换全部的诗时会删除的现有的诗，导入前提醒用
+func (p *Poems) ReplaceRemoved(list []*Poem) []*Poem {
+	_, removed := p.matchReplace(list)
+	return removed
+}
+
+// Import 在一个事务中替换全部的诗，失败时保持原样。
+// 与现有的诗相同的在原处更新，保留所有学习者的收藏、复习计划和练习统计；
+// 其余现有的诗连同学习记录一起删除，新的诗算作当前学习者已背的诗
+func (p *Poems) Import(list []*Poem) error {
+	matched, removed := p.matchReplace(list)
+	err := transaction(func(tx *gorm.DB) error {
+		if len(removed) != 0 {
+			ids := make([]uint64, len(removed))
+			for i, poem := range removed {
+				ids[i] = poem.ID
+			}
+			if err := tx.Delete(&Poem{}, ids).Error; err != nil {
 				tx.Rollback()
 				return err
 			}
 		}
-		return nil
-	})
-}
 
-func (p *Poems) Import(reader fyne.URIReadCloser) error {
-	if err := p.Clear(); err != nil {
-		return nil
-	}
+		for _, poem := range list {
+			if existing, ok := matched[poem]; ok {
+				if err := updatePoem(tx, existing, poem); err != nil {
+					tx.Rollback()
+					return err
+				}
+				continue
+			}
 
-	if err := p.Load(reader); err != nil {
+			if err := tx.Create(poem).Error; err != nil {
+				tx.Rollback()
+				return err
+			}
+			poem.Memorised = true
+			if err := p.saveState(tx, poem); err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
 		return nil
+	})
+	if err != nil {
+		return err
 	}
 
-	return p.createAll()
+	p.setList(list)
+	return nil
 }
 
-func (p *Poems) Store(writer fyne.URIWriteCloser) (err error) {
+func (p *Poems) Store(writer fyne.URIWriteCloser, list []*Poem) (err error) {
 	defer func(writer fyne.URIWriteCloser) {
-		err = writer.Close()
+		if closeErr := writer.Close(); err == nil {
+			err = closeErr
+		}
 	}(writer)
 
-	if data, err := json.Marshal(&p.list); err != nil {
+	if data, err := encodeExport(p.profile.Name, list); err != nil {
 		return err
 	} else {
 		block := data[:]
@@ -360,19 +561,49 @@ func (p *Poems) Store(writer fyne.URIWriteCloser) (err error) {
 	return nil
 }
 
-func (p *Poems) Export(writer fyne.URIWriteCloser) error {
-	return p.Store(writer)
+// Export 按格式导出选中的诗
+func (p *Poems) Export(writer fyne.URIWriteCloser, list []*Poem, opts *ExportOptions) (err error) {
+	if opts.Format == ExportJSON {
+		return p.Store(writer, list)
+	}
+
+	defer func(writer fyne.URIWriteCloser) {
+		if closeErr := writer.Close(); err == nil {
+			err = closeErr
+		}
+	}(writer)
+
+	switch opts.Format {
+	case ExportCSV:
+		return WriteTable(writer, list, ',', opts.LineSeparator)
+	case ExportTSV:
+		return WriteTable(writer, list, '\t', opts.LineSeparator)
+	case ExportEPUB:
+		return WriteEpub(writer, fmt.Sprintf("%s的诗集", p.profile.Name), list, opts.Group, opts.Ruby)
+	default:
+		return WriteWorksheet(writer, list, opts.Layout, opts.Keyword)
+	}
 }
 
+// Filter 有内容关键字时先用索引缩小范围，再逐首确认。模糊搜索时最接近的在前
 func (p *Poems) Filter(s *Search) []*Poem {
 	filtered := make([]*Poem, 0, len(p.list))
 
+	candidates, narrowed := p.index.candidates(s)
 	for _, poem := range p.list {
+		if narrowed {
+			if _, ok := candidates[poem]; !ok {
+				continue
+			}
+		}
 		if poem.Matched(s) {
 			filtered = append(filtered, poem)
 		}
 	}
 
+	if s.Fuzzy {
+		sortByFuzzyCost(filtered, s.Content)
+	}
 	return filtered
 }
 
@@ -384,6 +615,7 @@ func (p *Poems) Remove(poem *Poem) error {
 	for i, pm := range p.list {
 		if pm == poem {
 			p.list = append(p.list[:i], p.list[i+1:]...)
+			p.index.remove(poem)
 			break
 		}
 	}
@@ -403,40 +635,75 @@ func (p *Poems) NextNo() uint64 {
 	return no + 1
 }
 
-func (p *Poems) Modify(oldPoem *Poem, newPoem *Poem) error {
+// updatePoem 用newPoem替换oldPoem，保留学习者的收藏、已背状态和查看时间
+func updatePoem(tx *gorm.DB, oldPoem *Poem, newPoem *Poem) error {
 	newPoem.ID = oldPoem.ID
 	newPoem.Favor = oldPoem.Favor
+	newPoem.Memorised = oldPoem.Memorised
+	newPoem.Viewed = oldPoem.Viewed
+
+	if err := rekeyLineCards(tx, oldPoem, newPoem); err != nil {
+		return err
+	}
 
-	if err := db.Updates(newPoem).Error; err != nil {
+	// 重新分句，删除原来的分句
+	if err := tx.Where("poem_id = ?", newPoem.ID).Delete(&Segment{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Updates(newPoem).Error; err != nil {
 		return err
 	}
+	// Updates不保存空值，清空的附加信息要单独保存
+	return tx.Model(newPoem).Select("genre", "tags", "source", "note", "translation", "annotations").Updates(newPoem).Error
+}
 
+// replace 替换内存中的诗
+func (p *Poems) replace(newPoem *Poem) {
 	for i, pp := range p.list {
 		if pp.ID == newPoem.ID {
 			p.list[i] = newPoem
+			p.index.remove(pp)
+			p.index.add(newPoem)
 			break
 		}
 	}
-	return nil
 }
 
-func (p *Poems) Add(poem *Poem) error {
-	if err := db.Create(poem).Error; err != nil {
+func (p *Poems) Modify(oldPoem *Poem, newPoem *Poem) error {
+	err := transaction(func(tx *gorm.DB) error {
+		if err := updatePoem(tx, oldPoem, newPoem); err != nil {
+			tx.Rollback()
+			return err
+		}
+		return nil
+	})
+	if err != nil {
 		return err
 	}
 
-	p.list = append(p.list, poem)
+	p.replace(newPoem)
 	return nil
 }
 
-func (p *Poems) ToggleFavor(poem *Poem) error {
-	oldFavor := poem.Favor
-
-	poem.Favor = !oldFavor
-	if err := db.Updates(poem).Error; err != nil {
-		poem.Favor = oldFavor
+// Add 添加的诗算作当前学习者已背的诗
+func (p *Poems) Add(poem *Poem) error {
+	poem.Memorised = true
+	err := transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(poem).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := p.saveState(tx, poem); err != nil {
+			tx.Rollback()
+			return err
+		}
+		return nil
+	})
+	if err != nil {
 		return err
 	}
 
+	p.list = append(p.list, poem)
+	p.index.add(poem)
 	return nil
 }
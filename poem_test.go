@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/json"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"strings"
+	"testing"
+)
+
+// openTestDB 用内存数据库替换db，测试结束后恢复
+func openTestDB(t *testing.T) {
+	t.Helper()
+	old := db
+	mem, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 每个连接都是单独的内存数据库，只用一个连接
+	sqlDB, err := mem.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+
+	if err := mem.Exec("PRAGMA foreign_keys=ON").Error; err != nil {
+		t.Fatal(err)
+	}
+	err = mem.AutoMigrate(&Poem{}, &Segment{}, &Profile{}, &ProfilePoem{}, &ReviewCard{}, &DrillStat{}, &GameRecord{}, &GameRecordPlayer{}, &GameRecordLine{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db = mem
+	t.Cleanup(func() {
+		db = old
+		_ = sqlDB.Close()
+	})
+}
+
+// newTestPoems 用给定的诗建立诗库，不需要数据库
+func newTestPoems(list ...*Poem) *Poems {
+	p := NewPoems()
+	for i, poem := range list {
+		poem.ID = uint64(i + 1)
+		if poem.No == 0 {
+			poem.No = poem.ID
+		}
+	}
+	p.setList(list)
+	return p
+}
+
+func TestSegmentRange(t *testing.T) {
+	poem := NewPoem(1, "静夜思", "唐", "李白", "床前明月光，\n疑是地上霜。\n举头望明月，\n低头思故乡。")
+	tests := []struct {
+		seq      int
+		from, to int
+	}{
+		{0, 0, 6},
+		{1, 7, 13},
+		{3, 21, 27},
+	}
+	for _, tt := range tests {
+		from, to, ok := poem.segmentRange(poem.Segments[tt.seq])
+		if !ok || from != tt.from || to != tt.to {
+			t.Errorf("segmentRange(%d) = %d, %d, %v, want %d, %d", tt.seq, from, to, ok, tt.from, tt.to)
+		}
+	}
+}
+
+func TestImportKeepsProfileState(t *testing.T) {
+	openTestDB(t)
+	poems := NewPoems()
+	kept := NewPoem(1, "静夜思", "唐", "李白", "床前明月光，疑是地上霜。")
+	removed := NewPoem(2, "春晓", "唐", "孟浩然", "春眠不觉晓，处处闻啼鸟。")
+	for _, poem := range []*Poem{kept, removed} {
+		if err := db.Create(poem).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	poems.setList([]*Poem{kept, removed})
+
+	current, other := &Profile{Name: "甲"}, &Profile{Name: "乙"}
+	for _, profile := range []*Profile{current, other} {
+		if err := db.Create(profile).Error; err != nil {
+			t.Fatal(err)
+		}
+		for _, poem := range []*Poem{kept, removed} {
+			if err := db.Create(&ProfilePoem{ProfileID: profile.ID, PoemID: poem.ID, Favor: true, Memorised: true}).Error; err != nil {
+				t.Fatal(err)
+			}
+			if err := db.Create(&ReviewCard{ProfileID: profile.ID, PoemID: poem.ID, Ease: defaultEase}).Error; err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := poems.SelectProfile(current.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// 繁体的静夜思与现有的是同一首诗
+	same := NewPoem(7, "靜夜思", "唐", "李白", "床前明月光，疑是地上霜。")
+	added := NewPoem(8, "登鹳雀楼", "唐", "王之涣", "白日依山尽，黄河入海流。")
+	if removed := poems.ReplaceRemoved([]*Poem{same, added}); len(removed) != 1 || removed[0].Title != "春晓" {
+		t.Fatalf("ReplaceRemoved = %v, want 春晓", removed)
+	}
+	if err := poems.Import([]*Poem{same, added}); err != nil {
+		t.Fatal(err)
+	}
+
+	if same.ID != kept.ID || !same.Favor || !same.Memorised {
+		t.Errorf("matched poem: id %d favor %v memorised %v, want id %d with state kept", same.ID, same.Favor, same.Memorised, kept.ID)
+	}
+	if !added.Memorised {
+		t.Error("new poem is not memorised by the current profile")
+	}
+
+	var states, cards, segments int64
+	db.Model(&ProfilePoem{}).Where("profile_id = ? AND poem_id = ? AND favor", other.ID, kept.ID).Count(&states)
+	if states != 1 {
+		t.Error("other profile lost its favourite")
+	}
+	db.Model(&ReviewCard{}).Where("poem_id = ?", kept.ID).Count(&cards)
+	if cards != 2 {
+		t.Errorf("review cards of the matched poem = %d, want 2", cards)
+	}
+	// 删除的诗的ID可能被新增的诗重用，按总数检查
+	db.Model(&ReviewCard{}).Count(&cards)
+	db.Model(&Segment{}).Count(&segments)
+	if cards != 2 || segments != int64(len(same.Segments)+len(added.Segments)) {
+		t.Errorf("removed poem left review cards or segments: %d cards, %d segments", cards, segments)
+	}
+
+	var count int64
+	db.Model(&Poem{}).Count(&count)
+	if count != 2 || len(poems.Filter(EmptySearch())) != 2 {
+		t.Errorf("poems after import = %d in database, %d in memory, want 2", count, len(poems.Filter(EmptySearch())))
+	}
+}
+
+func TestPoemSource(t *testing.T) {
+	openTestDB(t)
+	poems := NewPoems()
+	profile, err := poems.AddProfile("甲")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := poems.SelectProfile(profile.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	poem := NewPoem(1, "静夜思", "唐代", "李白", "床前明月光，\n疑是地上霜。")
+	poem.Source = "唐诗三百首"
+	if err := poems.Add(poem); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := json.Marshal(poem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Poem
+	if err := json.Unmarshal(data, &decoded); err != nil || decoded.Source != "唐诗三百首" {
+		t.Errorf("json round trip: source %q, err %v in %s", decoded.Source, err, data)
+	}
+	if data, _ := json.Marshal(&Poem{Title: "无出处"}); strings.Contains(string(data), "source") {
+		t.Errorf("empty source is exported: %s", data)
+	}
+
+	modified := NewPoem(1, "静夜思", "唐代", "李白", "床前明月光，\n疑是地上霜。")
+	modified.Source = "小学语文"
+	if err := poems.Modify(poem, modified); err != nil {
+		t.Fatal(err)
+	}
+	var saved Poem
+	if err := db.First(&saved, modified.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if saved.Source != "小学语文" {
+		t.Errorf("saved source = %q, want 小学语文", saved.Source)
+	}
+	if !strings.Contains(modified.InfoMarkdown(), "出处：小学语文") {
+		t.Errorf("InfoMarkdown = %q", modified.InfoMarkdown())
+	}
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+const DefaultProfileName = "默认"
+
+// profilePreference 保存当前学习者ID的设置项
+const profilePreference = "profile"
+
+// Profile 学习者，每个学习者有自己已背的诗和收藏
+type Profile struct {
+	ID    uint64         `gorm:"primarykey"`
+	Name  string         `gorm:"uniqueIndex"`
+	Poems []*ProfilePoem `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
+
+// ProfilePoem 学习者与诗的关系
+type ProfilePoem struct {
+	ProfileID uint64 `gorm:"primaryKey"`
+	PoemID    uint64 `gorm:"primaryKey"`
+	Favor     bool
+	Memorised bool
+	Viewed    time.Time
+}
+
+// migrateProfiles 旧版本没有学习者，收藏保存在poems表的favor列中（该列保留不再使用），
+// 迁移为默认学习者的收藏，原有的诗都算作默认学习者已背的诗
+func migrateProfiles() error {
+	var count int64
+	if err := db.Model(&Profile{}).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	return transaction(func(tx *gorm.DB) error {
+		profile := &Profile{Name: DefaultProfileName}
+		if err := tx.Create(profile).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		favors := make(map[uint64]bool)
+		if tx.Migrator().HasColumn(&Poem{}, "favor") {
+			ids := make([]uint64, 0)
+			if err := tx.Table("poems").Where("favor = ?", true).Pluck("id", &ids).Error; err != nil {
+				tx.Rollback()
+				return err
+			}
+			for _, id := range ids {
+				favors[id] = true
+			}
+		}
+
+		ids := make([]uint64, 0)
+		if err := tx.Model(&Poem{}).Pluck("id", &ids).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+		for _, id := range ids {
+			if err := tx.Create(&ProfilePoem{ProfileID: profile.ID, PoemID: id, Favor: favors[id], Memorised: true}).Error; err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (p *Poems) Profile() *Profile {
+	return p.profile
+}
+
+func (p *Poems) Profiles() ([]*Profile, error) {
+	profiles := make([]*Profile, 0)
+	err := db.Order("id").Find(&profiles).Error
+	return profiles, err
+}
+
+// SelectProfile 切换学习者，找不到时切换到第一个学习者
+func (p *Poems) SelectProfile(id uint64) error {
+	profiles, err := p.Profiles()
+	if err != nil {
+		return err
+	}
+	if len(profiles) == 0 {
+		return errors.New("没有学习者")
+	}
+
+	profile := profiles[0]
+	for _, pf := range profiles {
+		if pf.ID == id {
+			profile = pf
+			break
+		}
+	}
+
+	rows := make([]*ProfilePoem, 0)
+	if err := db.Where("profile_id = ?", profile.ID).Find(&rows).Error; err != nil {
+		return err
+	}
+
+	states := make(map[uint64]*ProfilePoem, len(rows))
+	for _, row := range rows {
+		states[row.PoemID] = row
+	}
+
+	for _, poem := range p.list {
+		if state, ok := states[poem.ID]; ok {
+			poem.Favor, poem.Memorised, poem.Viewed = state.Favor, state.Memorised, state.Viewed
+		} else {
+			poem.Favor, poem.Memorised, poem.Viewed = false, false, time.Time{}
+		}
+	}
+
+	p.profile = profile
+	return nil
+}
+
+// MemorisedPoems 学习者已背的诗的ID，当前学习者用内存中的状态
+func (p *Poems) MemorisedPoems(profile *Profile) (map[uint64]bool, error) {
+	memorised := make(map[uint64]bool)
+	if p.profile != nil && profile.ID == p.profile.ID {
+		for _, poem := range p.list {
+			if poem.Memorised {
+				memorised[poem.ID] = true
+			}
+		}
+		return memorised, nil
+	}
+
+	ids := make([]uint64, 0)
+	if err := db.Model(&ProfilePoem{}).Where("profile_id = ? AND memorised", profile.ID).Pluck("poem_id", &ids).Error; err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		memorised[id] = true
+	}
+	return memorised, nil
+}
+
+func (p *Poems) AddProfile(name string) (*Profile, error) {
+	profile := &Profile{Name: name}
+	if err := db.Create(profile).Error; err != nil {
+		return nil, err
+	}
+	return profile, nil
+}
+
+// RemoveProfile 删除当前学习者，并切换到第一个学习者
+func (p *Poems) RemoveProfile() error {
+	profiles, err := p.Profiles()
+	if err != nil {
+		return err
+	}
+	if len(profiles) <= 1 {
+		return errors.New("至少保留一个学习者")
+	}
+
+	err = transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("profile_id = ?", p.profile.ID).Delete(&ProfilePoem{}).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Where("profile_id = ?", p.profile.ID).Delete(&ReviewCard{}).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Where("profile_id = ?", p.profile.ID).Delete(&DrillStat{}).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Delete(p.profile).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return p.SelectProfile(0)
+}
+
+// saveState 保存当前学习者对这首诗的收藏、已背状态和查看时间
+func (p *Poems) saveState(tx *gorm.DB, poem *Poem) error {
+	return tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(&ProfilePoem{
+		ProfileID: p.profile.ID,
+		PoemID:    poem.ID,
+		Favor:     poem.Favor,
+		Memorised: poem.Memorised,
+		Viewed:    poem.Viewed,
+	}).Error
+}
+
+func (p *Poems) ToggleFavor(poem *Poem) error {
+	oldFavor := poem.Favor
+
+	poem.Favor = !oldFavor
+	if err := p.saveState(db, poem); err != nil {
+		poem.Favor = oldFavor
+		return err
+	}
+
+	return nil
+}
+
+func (p *Poems) ToggleMemorised(poem *Poem) error {
+	oldMemorised := poem.Memorised
+
+	poem.Memorised = !oldMemorised
+	if err := p.saveState(db, poem); err != nil {
+		poem.Memorised = oldMemorised
+		return err
+	}
+
+	return nil
+}
+
+// MarkViewed 记录当前学习者查看了这首诗
+func (p *Poems) MarkViewed(poem *Poem) error {
+	oldViewed := poem.Viewed
+
+	poem.Viewed = time.Now()
+	if err := p.saveState(db, poem); err != nil {
+		poem.Viewed = oldViewed
+		return err
+	}
+
+	return nil
+}
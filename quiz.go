@@ -0,0 +1,127 @@
+package main
+
+import (
+	"errors"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"math/rand"
+	"strconv"
+	"time"
+)
+
+type QuizScreen struct {
+	root  fyne.CanvasObject
+	reset func()
+}
+
+func NewQuizScreen(poems *Poems, mgr *ScreenManager, win fyne.Window) *QuizScreen {
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	var quiz *Quiz
+	var inputs []*widget.Entry
+
+	ruleEntry := widget.NewEntry()
+	ruleEntry.SetPlaceHolder("出题范围，例如：d唐 或 a李白")
+	favorCheck := widget.NewCheck("仅收藏", nil)
+	mode := widget.NewSelect(QuizModeNames, nil)
+	mode.SetSelectedIndex(int(QuizLine))
+	count := newIntEntry(2, 1, 50)
+
+	text := widget.NewRichTextWithText("")
+	text.Wrapping = fyne.TextWrapWord
+	blanks := container.NewVBox()
+	var submitBtn *widget.Button
+
+	newQuiz := func() {
+		if err := count.Validate(); err != nil {
+			dialog.ShowError(err, win)
+			return
+		}
+
+		filtered := poems.Filter(NewSearch(ruleEntry.Text, favorCheck.Checked))
+		if len(filtered) == 0 {
+			dialog.ShowError(errors.New("没有符合条件的诗"), win)
+			return
+		}
+
+		poem := filtered[r.Intn(len(filtered))]
+		quiz = NewQuiz(poem, QuizMode(mode.SelectedIndex()), intEntryValue(count), r)
+
+		text.ParseMarkdown(quiz.Markdown())
+		inputs = make([]*widget.Entry, len(quiz.Blanks))
+		blanks.Objects = nil
+		for i := range quiz.Blanks {
+			inputs[i] = widget.NewEntry()
+			blanks.Add(container.NewBorder(nil, nil, widget.NewLabel("（"+strconv.Itoa(i+1)+"）"), nil, inputs[i]))
+		}
+		blanks.Refresh()
+		submitBtn.Enable()
+	}
+
+	submitBtn = widget.NewButtonWithIcon("提交", theme.ConfirmIcon(), func() {
+		if quiz == nil {
+			return
+		}
+
+		lines := make(map[int]bool)
+		for i, b := range quiz.Blanks {
+			b.Input = inputs[i].Text
+			inputs[i].Disable()
+			if ok, found := lines[b.Line]; !found || ok {
+				lines[b.Line] = b.Correct()
+			}
+		}
+
+		// 默写的结果计入这一句的复习计划
+		for line, ok := range lines {
+			grade := GradeGood
+			if !ok {
+				grade = GradeAgain
+			}
+			if err := poems.GradeLine(quiz.Poem, line+1, grade, time.Now()); err != nil {
+				dialog.ShowError(err, win)
+				break
+			}
+		}
+
+		text.ParseMarkdown(quiz.ResultMarkdown())
+		submitBtn.Disable()
+	})
+	nextBtn := widget.NewButtonWithIcon("出题", theme.ViewRefreshIcon(), newQuiz)
+	returnBtn := widget.NewButtonWithIcon("返回", theme.NavigateBackIcon(), func() {
+		mgr.SwitchTo("entry")
+	})
+
+	top := container.NewVBox(
+		container.NewBorder(nil, nil, favorCheck, nil, ruleEntry),
+		container.NewGridWithColumns(2, mode, container.NewBorder(nil, nil, widget.NewLabel("空数"), nil, count)),
+	)
+	root := container.NewBorder(top, container.NewGridWithColumns(3, returnBtn, nextBtn, submitBtn), nil, nil,
+		container.NewVScroll(container.NewVBox(text, blanks)))
+
+	reset := func() {
+		quiz = nil
+		text.ParseMarkdown("")
+		blanks.Objects = nil
+		blanks.Refresh()
+		submitBtn.Disable()
+	}
+	reset()
+
+	return &QuizScreen{root: root, reset: reset}
+}
+
+func (s *QuizScreen) Show(interface{}) {
+	s.reset()
+	s.root.Show()
+}
+
+func (s *QuizScreen) Hide() {
+	s.root.Hide()
+}
+
+func (s *QuizScreen) RootObj() fyne.CanvasObject {
+	return s.root
+}
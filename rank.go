@@ -0,0 +1,155 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// SortOrder 搜索结果的排列顺序
+type SortOrder int
+
+const (
+	SortRelevance SortOrder = iota
+	SortNo
+	SortTitle   // 标题的拼音
+	SortDynasty // 朝代的先后
+	SortViewed  // 最近查看的在前
+)
+
+var SortOrderNames = []string{"相关度", "序号", "标题拼音", "朝代", "最近查看"}
+
+// sortPreference 保存排列顺序的设置项
+const sortPreference = "sort"
+
+// 相关度中各部分的权重
+const (
+	weightTitle    = 5.0
+	weightAuthor   = 3.0
+	weightDynasty  = 1.0
+	weightSegment  = 2.0 // 每句含有关键字的诗句
+	weightPosition = 2.0 // 关键字出现得越早越好
+	weightFavor    = 1.5
+	weightFuzzy    = 1.0 // 模糊搜索时每一点编辑代价扣的分
+)
+
+// Score 诗与搜索的相关度，标题、作者中有关键字的比只在诗句中有的高，
+// 含有关键字的诗句越多、越靠前越高，收藏的诗略高
+func (s *Search) Score(p *Poem) float64 {
+	score := 0.0
+	title, author, dynasty := normalise(p.Title), normalise(p.Author), normalise(p.Dynasty)
+
+	for _, term := range s.Terms {
+		key := normalise(term.Key)
+		switch term.Field {
+		case FieldTitle:
+			if title == key {
+				score += weightTitle
+			}
+		case FieldAuthor:
+			if author == key {
+				score += weightAuthor
+			}
+		}
+	}
+
+	content, _ := s.keys()
+	for _, key := range content {
+		key = normalise(key)
+		if strings.Contains(title, key) {
+			score += weightTitle
+		}
+		if strings.Contains(author, key) {
+			score += weightAuthor
+		}
+		if strings.Contains(dynasty, key) {
+			score += weightDynasty
+		}
+	}
+
+	first := -1
+	for i, seg := range p.Segments {
+		if s.SegmentMatched(seg) {
+			score += weightSegment
+			if first < 0 {
+				first = i
+			}
+		}
+	}
+	if first >= 0 {
+		score += weightPosition / float64(first+1)
+	}
+
+	if s.Fuzzy {
+		score -= weightFuzzy * float64(p.fuzzyCost(s.Content))
+	}
+	if p.Favor {
+		score += weightFavor
+	}
+	return score
+}
+
+// dynastyOrder 朝代的先后，不认识的朝代排在最后
+var dynastyOrder = map[string]int{
+	"先秦": 1, "秦": 2, "汉": 3, "两汉": 3, "西汉": 3, "东汉": 4, "三国": 5, "魏": 5,
+	"晋": 6, "魏晋": 6, "西晋": 6, "东晋": 7, "南北朝": 8, "南朝": 8, "北朝": 8, "隋": 9,
+	"唐": 10, "五代": 11, "五代十国": 11, "宋": 12, "北宋": 12, "南宋": 13, "辽": 13, "金": 13,
+	"元": 14, "明": 15, "清": 16, "近代": 17, "近现代": 17, "现代": 18, "当代": 19,
+}
+
+func dynastyRank(dynasty string) int {
+	dynasty = normalise(strings.TrimSpace(dynasty))
+	if rank, ok := dynastyOrder[dynasty]; ok {
+		return rank
+	}
+	if rank, ok := dynastyOrder[strings.TrimRight(dynasty, "代朝")]; ok {
+		return rank
+	}
+	return len(dynastyOrder) + 1
+}
+
+// titleKey 按拼音排列标题用的键，不是汉字的保留原样
+func titleKey(title string) string {
+	text := normalise(title)
+	runes, py := []rune(text), Pinyin(text)
+	parts := make([]string, len(runes))
+	for i, r := range runes {
+		if len(py[i]) == 0 {
+			parts[i] = string(r)
+		} else {
+			parts[i] = toneless(py[i])
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// Sort 按顺序排列搜索结果，同样的按诗库的顺序。没有关键字时相关度没有意义，保持诗库的顺序
+func (p *Poems) Sort(list []*Poem, s *Search, order SortOrder) {
+	var less func(a, b *Poem) bool
+	switch order {
+	case SortNo:
+		less = func(a, b *Poem) bool { return a.No < b.No }
+	case SortTitle:
+		keys := make(map[*Poem]string, len(list))
+		for _, poem := range list {
+			keys[poem] = titleKey(poem.Title)
+		}
+		less = func(a, b *Poem) bool { return keys[a] < keys[b] }
+	case SortDynasty:
+		less = func(a, b *Poem) bool { return dynastyRank(a.Dynasty) < dynastyRank(b.Dynasty) }
+	case SortViewed:
+		less = func(a, b *Poem) bool { return a.Viewed.After(b.Viewed) }
+	default:
+		if !s.HasKeyword() {
+			return
+		}
+		scores := make(map[*Poem]float64, len(list))
+		for _, poem := range list {
+			scores[poem] = s.Score(poem)
+		}
+		less = func(a, b *Poem) bool { return scores[a] > scores[b] }
+	}
+
+	sort.SliceStable(list, func(i, j int) bool {
+		return less(list[i], list[j])
+	})
+}
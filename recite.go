@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+type QuizMode int
+
+const (
+	QuizLine QuizMode = iota // 挖去整句
+	QuizChar                 // 挖去单字
+)
+
+var QuizModeNames = []string{"整句", "单字"}
+
+// Blank 一个需要填写的空，Pos为-1时挖去整句
+type Blank struct {
+	Line   int // 第几句，从0开始
+	Pos    int // 句中第几个字，从0开始
+	Answer string
+	Input  string
+}
+
+// Mistakes 逐字比较，不区分简繁，返回答案中每个字是否写错
+func (b *Blank) Mistakes() []bool {
+	answer := []rune(normalise(b.Answer))
+	input := []rune(normalise(stripPunctuation(b.Input)))
+
+	mistakes := make([]bool, len(answer))
+	for i := range answer {
+		mistakes[i] = i >= len(input) || input[i] != answer[i]
+	}
+	return mistakes
+}
+
+func (b *Blank) Correct() bool {
+	if len([]rune(stripPunctuation(b.Input))) != len([]rune(b.Answer)) {
+		return false
+	}
+	for _, m := range b.Mistakes() {
+		if m {
+			return false
+		}
+	}
+	return true
+}
+
+// graded 答案中写错的字加粗
+func (b *Blank) graded() string {
+	var sb strings.Builder
+	mistakes := b.Mistakes()
+	for i, r := range []rune(b.Answer) {
+		if mistakes[i] {
+			sb.WriteString(highlight(string(r), string(r)))
+		} else {
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
+
+// Quiz 默写题，从一首诗中挖去若干句或若干字
+type Quiz struct {
+	Poem   *Poem
+	Mode   QuizMode
+	Blanks []*Blank
+}
+
+func NewQuiz(poem *Poem, mode QuizMode, n int, r *rand.Rand) *Quiz {
+	q := &Quiz{Poem: poem, Mode: mode, Blanks: make([]*Blank, 0, n)}
+
+	candidates := make([]*Blank, 0)
+	for i, seg := range poem.Segments {
+		text := []rune(seg.Text())
+		if mode == QuizLine {
+			// 与输入一样去掉句首的空白和句中的标点，例如五花马、千金裘
+			candidates = append(candidates, &Blank{Line: i, Pos: -1, Answer: stripPunctuation(string(text))})
+			continue
+		}
+		for j, c := range text {
+			if unicode.Is(unicode.Han, c) {
+				candidates = append(candidates, &Blank{Line: i, Pos: j, Answer: string(c)})
+			}
+		}
+	}
+
+	r.Shuffle(len(candidates), func(i, j int) {
+		candidates[i], candidates[j] = candidates[j], candidates[i]
+	})
+	if n > len(candidates) {
+		n = len(candidates)
+	}
+	q.Blanks = append(q.Blanks, candidates[:n]...)
+
+	sort.Slice(q.Blanks, func(i, j int) bool {
+		if q.Blanks[i].Line != q.Blanks[j].Line {
+			return q.Blanks[i].Line < q.Blanks[j].Line
+		}
+		return q.Blanks[i].Pos < q.Blanks[j].Pos
+	})
+
+	return q
+}
+
+// render 按句渲染，blank返回挖空处的内容
+func (q *Quiz) render(blank func(i int, b *Blank) string) string {
+	content := segmentsMarkdown(q.Poem.Segments, func(line int, seg *Segment) string {
+		text := []rune(seg.Text())
+		tail := strings.TrimPrefix(seg.Content, seg.Text())
+
+		var sb strings.Builder
+		pos := 0
+		for i, b := range q.Blanks {
+			if b.Line != line {
+				continue
+			}
+			if b.Pos < 0 {
+				return blank(i, b) + tail
+			}
+			sb.WriteString(string(text[pos:b.Pos]))
+			sb.WriteString(blank(i, b))
+			pos = b.Pos + 1
+		}
+		sb.WriteString(string(text[pos:]))
+		sb.WriteString(tail)
+		return sb.String()
+	})
+
+	return (&PoemDetailTemplateContext{Poem: q.Poem, MarkdownContent: content}).Markdown()
+}
+
+// Markdown 题目，挖空处显示序号
+func (q *Quiz) Markdown() string {
+	return q.render(func(i int, b *Blank) string {
+		if b.Pos < 0 {
+			return fmt.Sprintf("（%d）%s", i+1, strings.Repeat("＿", len([]rune(b.Answer))))
+		}
+		return fmt.Sprintf("（%d）", i+1)
+	})
+}
+
+// ResultMarkdown 批改结果，写错的字加粗
+func (q *Quiz) ResultMarkdown() string {
+	var sb strings.Builder
+	sb.WriteString(q.render(func(i int, b *Blank) string {
+		return b.graded()
+	}))
+
+	correct := 0
+	sb.WriteString("\n\n---\n\n")
+	for i, b := range q.Blanks {
+		mark := "✗"
+		if b.Correct() {
+			correct++
+			mark = "✓"
+		}
+		fmt.Fprintf(&sb, "%d. %s %s\n", i+1, stripPunctuation(b.Input), mark)
+	}
+	fmt.Fprintf(&sb, "\n共%d空，答对%d空\n", len(q.Blanks), correct)
+
+	return sb.String()
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestQuizLineAnswer(t *testing.T) {
+	poem := NewPoem(1, "测试", "唐代", "佚名", "五花马、千金裘，\n 何当共剪西窗烛。")
+	quiz := NewQuiz(poem, QuizLine, 2, rand.New(rand.NewSource(1)))
+	if len(quiz.Blanks) != 2 {
+		t.Fatalf("got %d blanks, want 2", len(quiz.Blanks))
+	}
+
+	tests := []struct {
+		input   string
+		correct bool
+	}{
+		{"五花马、千金裘", true},
+		{"五花马千金裘", true},
+		{"五花馬，千金裘。", true},
+		{"五花马千金", false},
+		{"五花牛千金裘", false},
+	}
+	blank := quiz.Blanks[0]
+	for _, tt := range tests {
+		blank.Input = tt.input
+		if got := blank.Correct(); got != tt.correct {
+			t.Errorf("Correct(%q) = %v, want %v", tt.input, got, tt.correct)
+		}
+	}
+
+	// 只有写错的字算错，后面的字不受标点影响
+	blank.Input = "五花牛、千金裘"
+	mistakes := blank.Mistakes()
+	for i, m := range mistakes {
+		if m != (i == 2) {
+			t.Errorf("Mistakes()[%d] = %v", i, m)
+		}
+	}
+
+	blank = quiz.Blanks[1]
+	blank.Input = "何当共剪西窗烛"
+	if !blank.Correct() {
+		t.Errorf("Correct(%q) = false for a line starting with a space", blank.Input)
+	}
+}
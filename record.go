@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"gorm.io/gorm"
+	"strings"
+	"time"
+)
+
+// GameRecord 一局飞花令的记录
+type GameRecord struct {
+	ID        uint64 `gorm:"primarykey"`
+	CreatedAt time.Time
+	Keyword   string
+	Rule      string
+	Winner    string
+	Loser     string
+	Players   []*GameRecordPlayer `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Lines     []*GameRecordLine   `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
+
+type GameRecordPlayer struct {
+	ID           uint64 `gorm:"primarykey"`
+	GameRecordID uint64
+	Name         string
+	AI           bool
+	Score        int
+	Mistakes     int
+}
+
+// GameRecordLine 玩家说出的诗句，诗被删除后仍然保留出处
+type GameRecordLine struct {
+	ID           uint64 `gorm:"primarykey"`
+	GameRecordID uint64
+	Player       string
+	Content      string
+	PoemID       uint64
+	Poem         string
+	Error        string
+}
+
+func NewGameRecord(g *Game) *GameRecord {
+	r := &GameRecord{
+		Keyword: g.Keyword,
+		Rule:    g.Rule.String(),
+		Players: make([]*GameRecordPlayer, 0, len(g.Players)),
+		Lines:   make([]*GameRecordLine, 0, len(g.Lines)),
+	}
+
+	if winner := g.Winner(); winner != nil {
+		r.Winner = winner.Name
+	}
+	if g.Loser != nil {
+		r.Loser = g.Loser.Name
+	}
+
+	for _, p := range g.Players {
+		r.Players = append(r.Players, &GameRecordPlayer{Name: p.Name, AI: p.AI != nil, Score: p.Score, Mistakes: p.Mistakes})
+	}
+
+	for _, l := range g.Lines {
+		line := &GameRecordLine{Player: l.Player.Name, Content: l.Text}
+		if l.Poem != nil {
+			line.PoemID = l.Poem.ID
+			line.Poem = l.Poem.Abstract()
+		}
+		if l.Err != nil {
+			line.Error = l.Err.Error()
+		}
+		r.Lines = append(r.Lines, line)
+	}
+
+	return r
+}
+
+func (r *GameRecord) Abstract() string {
+	return fmt.Sprintf("%s  关键字：%s  %s  胜者：%s", r.CreatedAt.Format("2006-01-02 15:04"), r.Keyword, r.Rule, r.Winner)
+}
+
+func (r *GameRecord) DetailMarkdown() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# 关键字：%s\n\n%s  %s\n\n", r.Keyword, r.CreatedAt.Format("2006-01-02 15:04"), r.Rule)
+	for _, p := range r.Players {
+		fmt.Fprintf(&b, "- %s：%d分，失误%d次\n", p.Name, p.Score, p.Mistakes)
+	}
+	fmt.Fprintf(&b, "\n胜者：**%s**  输者：%s\n\n", r.Winner, r.Loser)
+
+	for i, l := range r.Lines {
+		if len(l.Error) != 0 {
+			fmt.Fprintf(&b, "%d. %s：%s（%s）\n", i+1, l.Player, l.Content, l.Error)
+		} else {
+			fmt.Fprintf(&b, "%d. %s：**%s** —— %s\n", i+1, l.Player, l.Content, l.Poem)
+		}
+	}
+
+	return b.String()
+}
+
+func (r *GameRecord) Save() error {
+	return db.Create(r).Error
+}
+
+func LoadGameRecords() ([]*GameRecord, error) {
+	records := make([]*GameRecord, 0)
+	err := db.Preload("Players").Preload("Lines", func(tx *gorm.DB) *gorm.DB {
+		return tx.Order("id")
+	}).Order("id desc").Find(&records).Error
+	return records, err
+}
+
+func RemoveGameRecord(r *GameRecord) error {
+	return db.Delete(r).Error
+}
+
+// PoemUsage 每首诗在历史对局中被答对的次数
+func PoemUsage() (map[uint64]int, error) {
+	var rows []struct {
+		PoemID uint64
+		Count  int
+	}
+	err := db.Model(&GameRecordLine{}).Select("poem_id, count(*) as count").
+		Where("poem_id <> 0 AND error = ''").Group("poem_id").Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	usage := make(map[uint64]int, len(rows))
+	for _, row := range rows {
+		usage[row.PoemID] = row.Count
+	}
+	return usage, nil
+}
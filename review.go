@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/data/binding"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"time"
+)
+
+type ReviewScreen struct {
+	root   fyne.CanvasObject
+	update func()
+}
+
+// reviewMarkdown 显示全文，需要复习的句子加粗
+func reviewMarkdown(item *ReviewItem) string {
+	lines := make(map[int]bool, len(item.Lines))
+	for _, l := range item.Lines {
+		lines[l] = true
+	}
+
+	content := segmentsMarkdown(item.Poem.Segments, func(i int, seg *Segment) string {
+		if lines[i+1] {
+			return fmt.Sprintf(" **%s** ", seg.Content)
+		}
+		return seg.Content
+	})
+
+	return (&PoemDetailTemplateContext{Poem: item.Poem, MarkdownContent: content}).Markdown()
+}
+
+func NewReviewScreen(poems *Poems, mgr *ScreenManager, win fyne.Window) *ReviewScreen {
+	rule := binding.NewString()
+	count := widget.NewLabel("")
+	itemData := binding.NewUntypedList()
+
+	updateList := func() {
+		rule_, _ := rule.Get()
+		items, err := poems.DueReviews(NewSearch(rule_, false), time.Now())
+		if err != nil {
+			dialog.ShowError(err, win)
+			return
+		}
+
+		list := make([]interface{}, len(items))
+		for i := range list {
+			list[i] = items[i]
+		}
+		_ = itemData.Set(list)
+		count.SetText(fmt.Sprintf("今日待复习 %d 首", len(items)))
+	}
+	rule.AddListener(binding.NewDataListener(updateList))
+
+	var current *ReviewItem
+	title := widget.NewLabel("")
+	text := widget.NewRichTextWithText("")
+	text.Wrapping = fyne.TextWrapWord
+	showBtn := widget.NewButtonWithIcon("显示全文", theme.VisibilityIcon(), func() {
+		if current != nil {
+			text.ParseMarkdown(reviewMarkdown(current))
+			text.Show()
+		}
+	})
+
+	var browser, reviewer fyne.CanvasObject
+
+	gradeBtn := func(label string, grade int) *widget.Button {
+		return widget.NewButton(label, func() {
+			if current == nil {
+				return
+			}
+
+			if err := poems.GradePoem(current.Poem, grade, time.Now()); err != nil {
+				dialog.ShowError(err, win)
+				return
+			}
+			// 整首诗记住了，需要复习的句子也算复习过了
+			for _, l := range current.Lines {
+				if err := poems.GradeLine(current.Poem, l, grade, time.Now()); err != nil {
+					dialog.ShowError(err, win)
+					return
+				}
+			}
+
+			current = nil
+			updateList()
+			reviewer.Hide()
+			browser.Show()
+		})
+	}
+
+	itemList := widget.NewListWithData(itemData,
+		func() fyne.CanvasObject {
+			abstract := widget.NewLabel("")
+			state := widget.NewLabel("")
+			return container.NewBorder(nil, nil, nil, state, abstract)
+		},
+		func(item binding.DataItem, o fyne.CanvasObject) {
+			item.AddListener(binding.NewDataListener(func() {
+				i, _ := item.(binding.Untyped).Get()
+				r := i.(*ReviewItem)
+
+				objs := o.(*fyne.Container).Objects
+				abstract, state := objs[0].(*widget.Label), objs[1].(*widget.Label)
+
+				abstract.SetText(r.Poem.Abstract())
+				if r.Card == nil {
+					state.SetText("新")
+				} else if len(r.Lines) > 0 {
+					state.SetText(fmt.Sprintf("%d句待复习", len(r.Lines)))
+				} else {
+					state.SetText(fmt.Sprintf("上次 %s", r.Card.LastReview.Format("01-02")))
+				}
+			}))
+		})
+	itemList.OnSelected = func(id widget.ListItemID) {
+		itemList.Unselect(id)
+
+		i, err := itemData.GetValue(id)
+		if err != nil {
+			return
+		}
+		current = i.(*ReviewItem)
+		title.SetText(fmt.Sprintf("请背诵：%s", current.Poem.Abstract()))
+		text.Hide()
+		browser.Hide()
+		reviewer.Show()
+	}
+
+	ruleEntry := widget.NewEntryWithData(rule)
+	ruleEntry.SetPlaceHolder("按搜索规则筛选")
+	returnBtn := widget.NewButtonWithIcon("返回", theme.NavigateBackIcon(), func() {
+		mgr.SwitchTo("entry")
+	})
+	browser = container.NewBorder(container.NewBorder(nil, nil, nil, count, ruleEntry), returnBtn, nil, nil, itemList)
+
+	backBtn := widget.NewButtonWithIcon("返回", theme.NavigateBackIcon(), func() {
+		current = nil
+		reviewer.Hide()
+		browser.Show()
+	})
+	reviewer = container.NewBorder(container.NewVBox(title, showBtn),
+		container.NewVBox(container.NewGridWithColumns(4, gradeBtn("忘记", GradeAgain), gradeBtn("困难", GradeHard), gradeBtn("良好", GradeGood), gradeBtn("简单", GradeEasy)), backBtn),
+		nil, nil, container.NewVScroll(text))
+	reviewer.Hide()
+
+	return &ReviewScreen{root: container.NewMax(browser, reviewer), update: func() {
+		current = nil
+		updateList()
+		reviewer.Hide()
+		browser.Show()
+	}}
+}
+
+func (s *ReviewScreen) Show(interface{}) {
+	s.update()
+	s.root.Show()
+}
+
+func (s *ReviewScreen) Hide() {
+	s.root.Hide()
+}
+
+func (s *ReviewScreen) RootObj() fyne.CanvasObject {
+	return s.root
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"gorm.io/gorm"
+	"math"
+	"time"
+)
+
+// 复习评分，参考SM-2算法，低于GradeHard表示没有记住
+const (
+	GradeAgain = 1
+	GradeHard  = 3
+	GradeGood  = 4
+	GradeEasy  = 5
+)
+
+const defaultEase = 2.5
+
+// ReviewCard 学习者对一首诗（Line为0）或其中一句（Line从1开始）的复习计划
+type ReviewCard struct {
+	ID          uint64 `gorm:"primarykey"`
+	ProfileID   uint64 `gorm:"uniqueIndex:idx_review_card"`
+	PoemID      uint64 `gorm:"uniqueIndex:idx_review_card"`
+	Line        int    `gorm:"uniqueIndex:idx_review_card"`
+	Ease        float64
+	Interval    int // 天
+	Repetitions int
+	Due         time.Time
+	LastReview  time.Time
+}
+
+func startOfDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+// Grade 按SM-2算法根据评分安排下次复习
+func (c *ReviewCard) Grade(grade int, now time.Time) {
+	if grade < GradeHard {
+		c.Repetitions = 0
+		c.Interval = 1
+	} else {
+		switch c.Repetitions {
+		case 0:
+			c.Interval = 1
+		case 1:
+			c.Interval = 6
+		default:
+			c.Interval = int(math.Round(float64(c.Interval) * c.Ease))
+		}
+		c.Repetitions++
+	}
+
+	q := float64(5 - grade)
+	c.Ease += 0.1 - q*(0.08+q*0.02)
+	if c.Ease < 1.3 {
+		c.Ease = 1.3
+	}
+
+	c.LastReview = now
+	c.Due = startOfDay(now).AddDate(0, 0, c.Interval)
+}
+
+func (c *ReviewCard) IsDue(now time.Time) bool {
+	return c.Due.Before(startOfDay(now).AddDate(0, 0, 1))
+}
+
+// ReviewItem 今天需要复习的诗，Card为nil表示还没有复习过
+type ReviewItem struct {
+	Poem  *Poem
+	Card  *ReviewCard
+	Lines []int // 需要复习的句子
+}
+
+func (p *Poems) reviewCards() ([]*ReviewCard, error) {
+	cards := make([]*ReviewCard, 0)
+	err := db.Where("profile_id = ?", p.profile.ID).Find(&cards).Error
+	return cards, err
+}
+
+// DueReviews 当前学习者已背的诗中今天需要复习的
+func (p *Poems) DueReviews(s *Search, now time.Time) ([]*ReviewItem, error) {
+	cards, err := p.reviewCards()
+	if err != nil {
+		return nil, err
+	}
+
+	poemCards := make(map[uint64]*ReviewCard)
+	lineCards := make(map[uint64][]*ReviewCard)
+	for _, c := range cards {
+		if c.Line == 0 {
+			poemCards[c.PoemID] = c
+		} else {
+			lineCards[c.PoemID] = append(lineCards[c.PoemID], c)
+		}
+	}
+
+	items := make([]*ReviewItem, 0)
+	for _, poem := range p.Filter(s) {
+		if !poem.Memorised {
+			continue
+		}
+
+		item := &ReviewItem{Poem: poem, Card: poemCards[poem.ID], Lines: make([]int, 0)}
+		for _, c := range lineCards[poem.ID] {
+			if c.IsDue(now) {
+				item.Lines = append(item.Lines, c.Line)
+			}
+		}
+
+		if item.Card == nil || item.Card.IsDue(now) || len(item.Lines) > 0 {
+			items = append(items, item)
+		}
+	}
+
+	return items, nil
+}
+
+func (p *Poems) grade(poem *Poem, line int, grade int, now time.Time) error {
+	card := &ReviewCard{}
+	err := db.Where(map[string]interface{}{"profile_id": p.profile.ID, "poem_id": poem.ID, "line": line}).
+		Attrs(&ReviewCard{Ease: defaultEase}).
+		FirstOrInit(card).Error
+	if err != nil {
+		return err
+	}
+
+	card.Grade(grade, now)
+	return db.Save(card).Error
+}
+
+// GradePoem 复习整首诗后评分
+func (p *Poems) GradePoem(poem *Poem, grade int, now time.Time) error {
+	return p.grade(poem, 0, grade, now)
+}
+
+// GradeLine 复习第line句后评分，line从1开始
+func (p *Poems) GradeLine(poem *Poem, line int, grade int, now time.Time) error {
+	return p.grade(poem, line, grade, now)
+}
+
+// lineMapping 修改内容后原来的第几句变成了第几句，按去掉标点的文字对应，
+// 相同的句子按先后对应，找不到的句子不在结果中
+func lineMapping(oldSegments, newSegments []*Segment) map[int]int {
+	lines := make(map[string][]int)
+	for i, seg := range newSegments {
+		key := lineKey(seg)
+		lines[key] = append(lines[key], i+1)
+	}
+
+	mapping := make(map[int]int)
+	for i, seg := range oldSegments {
+		key := lineKey(seg)
+		if found := lines[key]; len(found) != 0 {
+			mapping[i+1] = found[0]
+			lines[key] = found[1:]
+		}
+	}
+	return mapping
+}
+
+// rekeyLineCards 修改诗的内容后，让所有学习者的逐句复习计划跟着句子走，删掉的句子的计划也删掉
+func rekeyLineCards(tx *gorm.DB, oldPoem *Poem, newPoem *Poem) error {
+	cards := make([]*ReviewCard, 0)
+	if err := tx.Where("poem_id = ? AND line <> 0", oldPoem.ID).Find(&cards).Error; err != nil {
+		return err
+	}
+	if len(cards) == 0 {
+		return nil
+	}
+
+	// 先全部删除再重新添加，避免调换顺序时唯一索引冲突
+	if err := tx.Where("poem_id = ? AND line <> 0", oldPoem.ID).Delete(&ReviewCard{}).Error; err != nil {
+		return err
+	}
+
+	mapping := lineMapping(oldPoem.Segments, newPoem.Segments)
+	kept := make([]*ReviewCard, 0, len(cards))
+	for _, card := range cards {
+		if line, ok := mapping[card.Line]; ok {
+			card.ID = 0
+			card.Line = line
+			kept = append(kept, card)
+		}
+	}
+	if len(kept) == 0 {
+		return nil
+	}
+	return tx.Create(kept).Error
+}
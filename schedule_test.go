@@ -0,0 +1,122 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReviewCardGrade(t *testing.T) {
+	now := time.Date(2023, 1, 10, 20, 0, 0, 0, time.Local)
+	tests := []struct {
+		name     string
+		grades   []int
+		interval int
+		ease     float64
+		reps     int
+	}{
+		{"first good", []int{GradeGood}, 1, 2.5, 1},
+		{"second good", []int{GradeGood, GradeGood}, 6, 2.5, 2},
+		{"third good", []int{GradeGood, GradeGood, GradeGood}, 15, 2.5, 3},
+		{"easy raises ease", []int{GradeEasy, GradeEasy, GradeEasy}, 16, 2.8, 3},
+		{"hard lowers ease", []int{GradeHard, GradeHard, GradeHard}, 13, 2.08, 3},
+		{"again resets", []int{GradeGood, GradeGood, GradeAgain}, 1, 1.96, 0},
+		{"ease has a floor", []int{GradeAgain, GradeAgain, GradeAgain, GradeAgain, GradeAgain}, 1, 1.3, 0},
+	}
+	for _, tt := range tests {
+		c := &ReviewCard{Ease: defaultEase}
+		for _, g := range tt.grades {
+			c.Grade(g, now)
+		}
+		if c.Interval != tt.interval || c.Repetitions != tt.reps || c.Ease < tt.ease-1e-9 || c.Ease > tt.ease+1e-9 {
+			t.Errorf("%s: interval %d reps %d ease %.2f, want %d %d %.2f", tt.name, c.Interval, c.Repetitions, c.Ease, tt.interval, tt.reps, tt.ease)
+		}
+		if want := startOfDay(now).AddDate(0, 0, tt.interval); !c.Due.Equal(want) {
+			t.Errorf("%s: due %v, want %v", tt.name, c.Due, want)
+		}
+	}
+}
+
+func TestReviewCardIsDue(t *testing.T) {
+	now := time.Date(2023, 1, 10, 20, 0, 0, 0, time.Local)
+	c := &ReviewCard{Ease: defaultEase}
+	c.Grade(GradeGood, now)
+	if c.IsDue(now) {
+		t.Error("card graded today is due today")
+	}
+	if !c.IsDue(now.AddDate(0, 0, 1).Add(-19 * time.Hour)) {
+		t.Error("card is not due early the next day")
+	}
+}
+
+func TestLineMapping(t *testing.T) {
+	segments := func(content string) []*Segment {
+		return NewPoem(1, "题", "唐", "某", content).Segments
+	}
+	tests := []struct {
+		name     string
+		old, new string
+		want     map[int]int
+	}{
+		{"unchanged", "甲乙，丙丁。", "甲乙，丙丁。", map[int]int{1: 1, 2: 2}},
+		{"inserted", "甲乙，丙丁。", "戊己，甲乙，丙丁。", map[int]int{1: 2, 2: 3}},
+		{"removed", "甲乙，丙丁，戊己。", "甲乙，戊己。", map[int]int{1: 1, 3: 2}},
+		{"swapped", "甲乙，丙丁。", "丙丁，甲乙。", map[int]int{1: 2, 2: 1}},
+		{"edited", "甲乙，丙丁。", "甲乙，丙戊。", map[int]int{1: 1}},
+		{"repeated", "甲乙，甲乙，丙丁。", "丙丁，甲乙。", map[int]int{1: 2, 3: 1}},
+	}
+	for _, tt := range tests {
+		got := lineMapping(segments(tt.old), segments(tt.new))
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: lineMapping = %v, want %v", tt.name, got, tt.want)
+			continue
+		}
+		for k, v := range tt.want {
+			if got[k] != v {
+				t.Errorf("%s: lineMapping = %v, want %v", tt.name, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestModifyKeepsLineCards(t *testing.T) {
+	openTestDB(t)
+	poems := NewPoems()
+	old := NewPoem(1, "题", "唐", "某", "甲乙，丙丁，戊己。")
+	if err := db.Create(old).Error; err != nil {
+		t.Fatal(err)
+	}
+	poems.setList([]*Poem{old})
+
+	for profile := uint64(1); profile <= 2; profile++ {
+		for line := 0; line <= 3; line++ {
+			card := &ReviewCard{ProfileID: profile, PoemID: old.ID, Line: line, Ease: defaultEase, Interval: line}
+			if err := db.Create(card).Error; err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	if err := poems.Modify(old, NewPoem(1, "题", "唐", "某", "戊己，甲乙，庚辛。")); err != nil {
+		t.Fatal(err)
+	}
+
+	cards := make([]*ReviewCard, 0)
+	if err := db.Order("profile_id, line").Find(&cards).Error; err != nil {
+		t.Fatal(err)
+	}
+	got := make([][2]int, 0, len(cards))
+	for _, c := range cards {
+		got = append(got, [2]int{c.Line, c.Interval})
+	}
+	// 整首诗的计划不变，甲乙从第1句变成第2句，戊己从第3句变成第1句，丙丁的计划删除
+	want := [][2]int{{0, 0}, {1, 3}, {2, 1}, {0, 0}, {1, 3}, {2, 1}}
+	if len(got) != len(want) {
+		t.Fatalf("cards = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("cards = %v, want %v", got, want)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	_ "embed"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// 简繁对照表来自OpenCC，逐字转换，一字多义时取第一个
+//
+//go:embed STCharacters.txt
+var _stCharacters []byte
+
+//go:embed TSCharacters.txt
+var _tsCharacters []byte
+
+type Script int
+
+const (
+	ScriptOriginal Script = iota
+	ScriptSimplified
+	ScriptTraditional
+)
+
+var ScriptNames = []string{"原文", "简体", "繁体"}
+
+const scriptPreference = "script"
+
+var (
+	scriptOnce    sync.Once
+	toSimplified  map[rune]rune
+	toTraditional map[rune]rune
+	displayScript = ScriptOriginal
+)
+
+func loadScriptTable(data []byte) map[rune]rune {
+	table := make(map[rune]rune)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+
+		from, _ := utf8.DecodeRuneInString(fields[0])
+		to, _ := utf8.DecodeRuneInString(fields[1])
+		table[from] = to
+	}
+	return table
+}
+
+func convertScript(text string, table map[rune]rune) string {
+	return strings.Map(func(r rune) rune {
+		if c, ok := table[r]; ok {
+			return c
+		}
+		return r
+	}, text)
+}
+
+// Convert 转换成s的字体，字数不变
+func (s Script) Convert(text string) string {
+	scriptOnce.Do(func() {
+		toSimplified = loadScriptTable(_tsCharacters)
+		toTraditional = loadScriptTable(_stCharacters)
+	})
+
+	switch s {
+	case ScriptSimplified:
+		return convertScript(text, toSimplified)
+	case ScriptTraditional:
+		return convertScript(text, toTraditional)
+	default:
+		return text
+	}
+}
+
+// normalise 统一成简体后再比较
+func normalise(text string) string {
+	return ScriptSimplified.Convert(text)
+}
+
+func SetDisplayScript(s Script) {
+	displayScript = s
+}
+
+// display 按设置的字体显示
+func display(text string) string {
+	return displayScript.Convert(text)
+}
+
+// findVariants 找出text中与key只有简繁不同的文字
+func findVariants(text, key string) []string {
+	original, normalised, target := []rune(text), []rune(normalise(text)), []rune(normalise(key))
+	if len(target) == 0 {
+		return nil
+	}
+
+	found := make(map[string]bool)
+	variants := make([]string, 0, 1)
+	for i := 0; i+len(target) <= len(normalised); i++ {
+		if string(normalised[i:i+len(target)]) != string(target) {
+			continue
+		}
+		if variant := string(original[i : i+len(target)]); !found[variant] {
+			found[variant] = true
+			variants = append(variants, variant)
+		}
+	}
+	return variants
+}
@@ -1,60 +1,476 @@
 package main
 
 import (
+	"errors"
+	"fmt"
 	"strconv"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
+// SearchField 搜索条件针对的字段
+type SearchField int
+
+const (
+	FieldContent SearchField = iota
+	FieldTitle
+	FieldDynasty
+	FieldAuthor
+	FieldNo
+	FieldAt     // 关键字在诗句中的位置，例如 花@2
+	FieldTag    // 标签，必须完全相同
+	FieldGenre  // 体裁
+	FieldSource // 出处
+	FieldNote   // 备注、译文和注释
+)
+
+// searchFields 字段名和别名，t、d、a是以前的单字母前缀
+var searchFields = map[string]SearchField{
+	"title": FieldTitle, "t": FieldTitle, "标题": FieldTitle,
+	"dynasty": FieldDynasty, "d": FieldDynasty, "朝代": FieldDynasty,
+	"author": FieldAuthor, "a": FieldAuthor, "作者": FieldAuthor,
+	"no": FieldNo, "n": FieldNo, "序号": FieldNo,
+	"content": FieldContent, "c": FieldContent, "内容": FieldContent,
+	"tag": FieldTag, "标签": FieldTag,
+	"genre": FieldGenre, "体裁": FieldGenre,
+	"source": FieldSource, "出处": FieldSource,
+	"note": FieldNote, "备注": FieldNote,
+}
+
+// SearchTerm 一个搜索条件，序号可以是范围From-To
+type SearchTerm struct {
+	Field    SearchField
+	Key      string
+	Pos      int
+	From, To uint64
+}
+
+// Matched 诗是否满足这个条件，不区分简繁
+func (t *SearchTerm) Matched(p *Poem) bool {
+	switch t.Field {
+	case FieldTitle:
+		return strings.Contains(normalise(p.Title), normalise(t.Key))
+	case FieldDynasty:
+		return strings.Contains(normalise(p.Dynasty), normalise(t.Key))
+	case FieldAuthor:
+		return strings.Contains(normalise(p.Author), normalise(t.Key))
+	case FieldNo:
+		return p.No >= t.From && p.No <= t.To
+	case FieldTag:
+		return p.HasTag(t.Key)
+	case FieldGenre:
+		return strings.Contains(normalise(p.Genre), normalise(t.Key))
+	case FieldSource:
+		return strings.Contains(normalise(p.Source), normalise(t.Key))
+	case FieldNote:
+		for _, text := range p.notes() {
+			if strings.Contains(normalise(text), normalise(t.Key)) {
+				return true
+			}
+		}
+		return false
+	case FieldAt:
+		for _, seg := range p.Segments {
+			if keywordAt(seg.Text(), t.Key, t.Pos) {
+				return true
+			}
+		}
+		return false
+	default:
+		return p.Contains(t.Key)
+	}
+}
+
+// Search 所有条件同时满足才算匹配。
+// 内容关键字和位置关键字单独存放，倒排索引、高亮和飞花令都用到它们
 type Search struct {
-	No        uint64
-	Title     string
-	Dynasty   string
-	Author    string
-	Content   []string
-	FavorOnly bool
+	Terms         []*SearchTerm // 标题、朝代、作者、序号
+	Content       []string
+	At            []*KeywordAt
+	Either        [][]*SearchTerm // 用|连接的条件，满足其中一个即可
+	Exclude       []*SearchTerm   // 用-排除的条件
+	FavorOnly     bool
+	MemorisedOnly bool
+	Fuzzy         bool // 内容关键字允许错字，同音字和形近字错得较轻
+}
+
+// KeywordAt 关键字必须出现在诗句的第Pos个字，Pos从1开始
+type KeywordAt struct {
+	Key string
+	Pos int
+}
+
+// keywordAt 判断text的第pos个字开始是否为key，不区分简繁，不计标点和空白
+func keywordAt(text, key string, pos int) bool {
+	r := []rune(normalise(stripPunctuation(text)))
+	if pos < 1 || pos > len(r) {
+		return false
+	}
+
+	return strings.HasPrefix(string(r[pos-1:]), normalise(key))
+}
+
+// parseKeywordAt 解析 花@2 形式的位置关键字
+func parseKeywordAt(part string) (*KeywordAt, bool) {
+	i := strings.LastIndex(part, "@")
+	if i <= 0 {
+		return nil, false
+	}
+
+	pos, err := strconv.Atoi(part[i+1:])
+	if err != nil || pos < 1 {
+		return nil, false
+	}
+
+	return &KeywordAt{Key: part[:i], Pos: pos}, true
 }
 
 func EmptySearch() *Search {
 	return &Search{
-		No:        0,
-		Title:     "",
-		Dynasty:   "",
-		Author:    "",
-		Content:   make([]string, 0),
-		FavorOnly: false,
+		Terms:         make([]*SearchTerm, 0),
+		Content:       make([]string, 0),
+		At:            make([]*KeywordAt, 0),
+		Either:        make([][]*SearchTerm, 0),
+		Exclude:       make([]*SearchTerm, 0),
+		FavorOnly:     false,
+		MemorisedOnly: false,
 	}
 }
 
+// NewSearch 解析搜索规则，有语法错误的部分被忽略
 func NewSearch(rule string, favorOnly bool) *Search {
+	s, _ := ParseSearch(rule, favorOnly)
+	return s
+}
+
+// ParseSearch 解析搜索规则，返回第一个语法错误。
+//
+// 规则由空格分开的条件组成：
+//
+//	月 花          内容同时含有月和花
+//	title:静夜思   字段可以是title/author/dynasty/no，或者t/a/d/n，或者标题/作者/朝代/序号
+//	tag:送别       还有tag/标签、genre/体裁、source/出处、note/备注，备注也查找译文和注释
+//	t静夜思        以前的写法，单字母后面直接跟中文
+//	月|花          含有月或花
+//	-月            不含月
+//	"a b"          引号中的空格、|、-等都是关键字的一部分
+//	10  10-20      序号或序号的范围
+//	花@2           第2个字是花
+func ParseSearch(rule string, favorOnly bool) (*Search, error) {
 	s := EmptySearch()
+	s.FavorOnly = favorOnly
 
-	rule = strings.TrimSpace(rule)
-	parts := strings.Split(rule, " ")
-	for _, part := range parts {
-		if len(part) == 0 {
+	clauses, err := splitClauses(rule)
+	for _, clause := range clauses {
+		if e := s.addClause(clause); e != nil && err == nil {
+			err = e
+		}
+	}
+
+	return s, err
+}
+
+// isQuote 支持英文和中文的双引号
+func isQuote(r rune) bool {
+	return r == '"' || r == '“' || r == '”'
+}
+
+// splitClauses 按引号外的空白拆分
+func splitClauses(rule string) ([]string, error) {
+	clauses := make([]string, 0)
+	var b strings.Builder
+	quoted := false
+	for _, r := range rule {
+		if isQuote(r) {
+			quoted = !quoted
+		} else if !quoted && unicode.IsSpace(r) {
+			if b.Len() != 0 {
+				clauses = append(clauses, b.String())
+				b.Reset()
+			}
+			continue
+		}
+		b.WriteRune(r)
+	}
+	if b.Len() != 0 {
+		clauses = append(clauses, b.String())
+	}
+
+	if quoted {
+		return clauses[:len(clauses)-1], errors.New("引号没有配对")
+	}
+	return clauses, nil
+}
+
+// splitAlternatives 按引号外的|拆分
+func splitAlternatives(clause string) []string {
+	parts := make([]string, 0, 1)
+	var b strings.Builder
+	quoted := false
+	for _, r := range clause {
+		if isQuote(r) {
+			quoted = !quoted
+		} else if !quoted && r == '|' {
+			parts = append(parts, b.String())
+			b.Reset()
 			continue
 		}
+		b.WriteRune(r)
+	}
+	return append(parts, b.String())
+}
+
+func (s *Search) addClause(clause string) error {
+	negated := false
+	body := clause
+	if strings.HasPrefix(body, "-") {
+		negated, body = true, body[1:]
+	}
+
+	parts := splitAlternatives(body)
+	terms := make([]*SearchTerm, 0, len(parts))
+	for _, part := range parts {
+		term, err := parseTerm(part)
+		if err != nil {
+			return fmt.Errorf("“%s”%w", clause, err)
+		}
+		terms = append(terms, term)
+	}
+
+	switch {
+	case negated && len(terms) == 1:
+		s.Exclude = append(s.Exclude, terms[0])
+	case negated:
+		// -月|花 即不含月也不含花
+		s.Exclude = append(s.Exclude, terms...)
+	case len(terms) > 1:
+		s.Either = append(s.Either, terms)
+	default:
+		s.addTerm(terms[0])
+	}
+	return nil
+}
+
+func (s *Search) addTerm(term *SearchTerm) {
+	switch term.Field {
+	case FieldContent:
+		s.Content = append(s.Content, term.Key)
+	case FieldAt:
+		s.At = append(s.At, &KeywordAt{Key: term.Key, Pos: term.Pos})
+	default:
+		s.Terms = append(s.Terms, term)
+	}
+}
+
+// unquote 去掉引号，返回是否有引号
+func unquote(text string) (string, bool) {
+	if strings.IndexFunc(text, isQuote) < 0 {
+		return text, false
+	}
+	return strings.Map(func(r rune) rune {
+		if isQuote(r) {
+			return -1
+		}
+		return r
+	}, text), true
+}
+
+// splitField 拆出字段名，以前的t、d、a前缀后面必须直接跟中文，
+// 这样tian、ai之类的拼音不会被当成字段
+func splitField(part string) (SearchField, string, bool) {
+	if i := strings.IndexAny(part, ":："); i > 0 {
+		if field, ok := searchFields[strings.ToLower(part[:i])]; ok {
+			_, size := utf8.DecodeRuneInString(part[i:])
+			return field, part[i+size:], true
+		}
+	}
 
-		if strings.HasPrefix(part, "t") {
-			s.Title = part[1:]
-		} else if strings.HasPrefix(part, "d") {
-			s.Dynasty = part[1:]
-		} else if strings.HasPrefix(part, "a") {
-			s.Author = part[1:]
-		} else {
-			if i, err := strconv.ParseUint(part, 10, 64); err != nil {
-				s.Content = append(s.Content, part)
-			} else {
-				s.No = i
+	if len(part) > 1 {
+		next, _ := utf8.DecodeRuneInString(part[1:])
+		if next > unicode.MaxASCII && !isQuote(next) {
+			switch part[0] {
+			case 't':
+				return FieldTitle, part[1:], true
+			case 'd':
+				return FieldDynasty, part[1:], true
+			case 'a':
+				return FieldAuthor, part[1:], true
 			}
 		}
 	}
 
-	s.FavorOnly = favorOnly
+	return FieldContent, part, false
+}
 
-	return s
+// parseNoRange 解析 10 或者 10-20
+func parseNoRange(text string) (uint64, uint64, bool, error) {
+	from, to, isRange := strings.Cut(text, "-")
+	a, err := strconv.ParseUint(from, 10, 64)
+	if err != nil {
+		return 0, 0, false, nil
+	}
+	if !isRange {
+		return a, a, true, nil
+	}
+	b, err := strconv.ParseUint(to, 10, 64)
+	if err != nil {
+		return 0, 0, false, nil
+	}
+	if a > b {
+		return 0, 0, true, fmt.Errorf("序号范围%d-%d的起点大于终点", a, b)
+	}
+	return a, b, true, nil
+}
+
+func parseTerm(part string) (*SearchTerm, error) {
+	field, text, explicit := splitField(part)
+	text, quoted := unquote(text)
+	if len(strings.TrimSpace(text)) == 0 {
+		return nil, errors.New("缺少关键字")
+	}
+
+	switch field {
+	case FieldNo:
+		from, to, ok, err := parseNoRange(text)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, fmt.Errorf("%s不是序号或序号范围", text)
+		}
+		return &SearchTerm{Field: FieldNo, From: from, To: to}, nil
+	case FieldContent:
+		// 引号中的都是关键字，不解析序号和位置
+		if quoted {
+			return &SearchTerm{Field: FieldContent, Key: text}, nil
+		}
+		if !explicit {
+			from, to, ok, err := parseNoRange(text)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				return &SearchTerm{Field: FieldNo, From: from, To: to}, nil
+			}
+		}
+		if at, ok := parseKeywordAt(text); ok {
+			return &SearchTerm{Field: FieldAt, Key: at.Key, Pos: at.Pos}, nil
+		}
+		return &SearchTerm{Field: FieldContent, Key: text}, nil
+	default:
+		return &SearchTerm{Field: field, Key: text}, nil
+	}
 }
 
 func (s *Search) HasKeyword() bool {
-	return (len(s.Title) != 0) || (len(s.Dynasty) != 0) || (len(s.Author) != 0) || (len(s.Content) != 0)
+	for _, term := range s.Terms {
+		if term.Field != FieldNo {
+			return true
+		}
+	}
+	return (len(s.Content) != 0) || (len(s.At) != 0) || (len(s.Either) != 0) || (len(s.Exclude) != 0)
+}
+
+// termsMatched 诗满足字段、|和-的条件，内容和位置关键字由Poem.Matched检查
+func (s *Search) termsMatched(p *Poem) bool {
+	for _, term := range s.Terms {
+		if !term.Matched(p) {
+			return false
+		}
+	}
+
+	for _, terms := range s.Either {
+		matched := false
+		for _, term := range terms {
+			if term.Matched(p) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	for _, term := range s.Exclude {
+		if term.Matched(p) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// keys 所有需要高亮的内容关键字和位置关键字，包括|连接的
+func (s *Search) keys() ([]string, []*KeywordAt) {
+	content := append(make([]string, 0, len(s.Content)), s.Content...)
+	at := append(make([]*KeywordAt, 0, len(s.At)), s.At...)
+	for _, terms := range s.Either {
+		for _, term := range terms {
+			switch term.Field {
+			case FieldContent:
+				content = append(content, term.Key)
+			case FieldAt:
+				at = append(at, &KeywordAt{Key: term.Key, Pos: term.Pos})
+			}
+		}
+	}
+	return content, at
+}
+
+// Highlights 返回需要在诗中高亮的文字，简繁不同或拼音的关键字替换为诗中对应的文字
+func (s *Search) Highlights(poem *Poem) []string {
+	content, positions := s.keys()
+	keys := make([]string, 0, len(content)+len(positions))
+	for _, key := range content {
+		variants := findVariants(poem.Content, key)
+		if len(variants) == 0 && s.Fuzzy && !isPinyinKey(key) {
+			variants = poem.fuzzyTexts(key)
+		}
+		if len(variants) != 0 || !isPinyinKey(key) {
+			keys = append(keys, variants...)
+			continue
+		}
+
+		found := make(map[string]bool)
+		for _, seg := range poem.Segments {
+			if text, ok := seg.FindPinyin(key); ok && !found[text] {
+				found[text] = true
+				keys = append(keys, text)
+			}
+		}
+	}
+	for _, at := range positions {
+		keys = append(keys, findVariants(poem.Content, at.Key)...)
+	}
+	return keys
+}
+
+// SegmentMatched 诗句含有任一关键字，或关键字出现在指定位置
+func (s *Search) SegmentMatched(seg *Segment) bool {
+	content, positions := s.keys()
+	for _, key := range content {
+		if strings.Contains(normalise(seg.Content), normalise(key)) {
+			return true
+		}
+		if isPinyinKey(key) {
+			if _, ok := seg.FindPinyin(key); ok {
+				return true
+			}
+		} else if s.Fuzzy {
+			if _, cost := fuzzyFind(seg.Text(), key); cost >= 0 && cost <= maxFuzzyCost(len([]rune(key))) {
+				return true
+			}
+		}
+	}
+
+	text := seg.Text()
+	for _, at := range positions {
+		if keywordAt(text, at.Key, at.Pos) {
+			return true
+		}
+	}
+
+	return false
 }
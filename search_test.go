@@ -0,0 +1,235 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// formatTerm 条件的简短写法，比较解析结果用
+func formatTerm(t *SearchTerm) string {
+	switch t.Field {
+	case FieldNo:
+		return fmt.Sprintf("no:%d-%d", t.From, t.To)
+	case FieldAt:
+		return fmt.Sprintf("%s@%d", t.Key, t.Pos)
+	}
+
+	names := map[SearchField]string{
+		FieldContent: "content", FieldTitle: "title", FieldDynasty: "dynasty", FieldAuthor: "author",
+		FieldTag: "tag", FieldGenre: "genre", FieldSource: "source", FieldNote: "note",
+	}
+	return names[t.Field] + ":" + t.Key
+}
+
+func formatTerms(terms []*SearchTerm) string {
+	parts := make([]string, len(terms))
+	for i, t := range terms {
+		parts[i] = formatTerm(t)
+	}
+	return strings.Join(parts, " ")
+}
+
+// formatSearch 解析结果的简短写法，各部分用;分开：条件;内容;位置;或;排除
+func formatSearch(s *Search) string {
+	at := make([]string, len(s.At))
+	for i, a := range s.At {
+		at[i] = fmt.Sprintf("%s@%d", a.Key, a.Pos)
+	}
+	either := make([]string, len(s.Either))
+	for i, terms := range s.Either {
+		parts := make([]string, len(terms))
+		for j, t := range terms {
+			parts[j] = formatTerm(t)
+		}
+		either[i] = strings.Join(parts, "|")
+	}
+	return strings.Join([]string{
+		formatTerms(s.Terms),
+		strings.Join(s.Content, " "),
+		strings.Join(at, " "),
+		strings.Join(either, " "),
+		formatTerms(s.Exclude),
+	}, ";")
+}
+
+func TestParseSearch(t *testing.T) {
+	tests := []struct {
+		rule string
+		want string
+	}{
+		{"", ";;;;"},
+		{"月 花", ";月 花;;;"},
+		{"  月\t花  ", ";月 花;;;"},
+
+		// 字段和别名
+		{"title:静夜思", "title:静夜思;;;;"},
+		{"t:静夜思", "title:静夜思;;;;"},
+		{"标题：静夜思", "title:静夜思;;;;"},
+		{"Author:李白", "author:李白;;;;"},
+		{"作者:李白 朝代:唐", "author:李白 dynasty:唐;;;;"},
+		{"d:唐", "dynasty:唐;;;;"},
+		{"content:月", ";月;;;"},
+		{"内容:10", ";10;;;"},
+		{"tag:送别", "tag:送别;;;;"},
+		{"标签:送别", "tag:送别;;;;"},
+		{"genre:七绝 体裁:五绝", "genre:七绝 genre:五绝;;;;"},
+		{"note:长安 备注:离别", "note:长安 note:离别;;;;"},
+		{"source:唐诗三百首 出处：课本", "source:唐诗三百首 source:课本;;;;"},
+		{"unknown:月", ";unknown:月;;;"},
+
+		// 以前的单字母前缀
+		{"t静夜思", "title:静夜思;;;;"},
+		{"a李白", "author:李白;;;;"},
+		{"d唐", "dynasty:唐;;;;"},
+		{"tian", ";tian;;;"},
+		{"ai", ";ai;;;"},
+
+		// 序号
+		{"10", "no:10-10;;;;"},
+		{"10-20", "no:10-20;;;;"},
+		{"no:5", "no:5-5;;;;"},
+		{"序号:5-6", "no:5-6;;;;"},
+
+		// 位置
+		{"花@2", ";;花@2;;"},
+		{"春风@1 月", ";月;春风@1;;"},
+		{"@2", ";@2;;;"},
+		{"花@0", ";花@0;;;"},
+
+		// 或
+		{"月|花", ";;;content:月|content:花;"},
+		{"a李白|a杜甫 月", ";月;;author:李白|author:杜甫;"},
+		{"花@1|10", ";;;花@1|no:10-10;"},
+
+		// 排除
+		{"-月", ";;;;content:月"},
+		{"月 -a李白", ";月;;;author:李白"},
+		{"-月|花", ";;;;content:月 content:花"},
+
+		// 引号
+		{`"明月 光"`, ";明月 光;;;"},
+		{`“花|月”`, ";花|月;;;"},
+		{`"10"`, ";10;;;"},
+		{`"花@2"`, ";花@2;;;"},
+		{`title:"月下 独酌"`, "title:月下 独酌;;;;"},
+		{`-"a b"`, ";;;;content:a b"},
+	}
+	for _, tt := range tests {
+		s, err := ParseSearch(tt.rule, false)
+		if err != nil {
+			t.Errorf("ParseSearch(%q) error: %v", tt.rule, err)
+			continue
+		}
+		if got := formatSearch(s); got != tt.want {
+			t.Errorf("ParseSearch(%q) = %q, want %q", tt.rule, got, tt.want)
+		}
+	}
+}
+
+func TestParseSearchErrors(t *testing.T) {
+	tests := []struct {
+		rule string
+		want string // 出错后仍然解析出来的部分
+		err  string
+	}{
+		{`月 "花`, ";月;;;", "引号没有配对"},
+		{"20-10 月", ";月;;;", "起点大于终点"},
+		{"no:abc", ";;;;", "不是序号"},
+		{"title: 月", ";月;;;", "缺少关键字"},
+		{"月 -", ";月;;;", "缺少关键字"},
+		{"月|", ";;;;", "缺少关键字"},
+		{`""`, ";;;;", "缺少关键字"},
+		{"no:1-2 no:3-1 no:x", "no:1-2;;;;", "起点大于终点"},
+	}
+	for _, tt := range tests {
+		s, err := ParseSearch(tt.rule, false)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("ParseSearch(%q) error = %v, want %q", tt.rule, err, tt.err)
+		}
+		if got := formatSearch(s); got != tt.want {
+			t.Errorf("ParseSearch(%q) = %q, want %q", tt.rule, got, tt.want)
+		}
+	}
+}
+
+func TestParseSearchFavorOnly(t *testing.T) {
+	s, err := ParseSearch("月", true)
+	if err != nil || !s.FavorOnly {
+		t.Errorf("FavorOnly = %v, err = %v", s.FavorOnly, err)
+	}
+}
+
+func TestKeywordAt(t *testing.T) {
+	tests := []struct {
+		text, key string
+		pos       int
+		want      bool
+	}{
+		{"床前明月光", "明月", 3, true},
+		{"床前明月光", "明月", 2, false},
+		{"長安一片月", "长安", 1, true},
+		{" 何当共剪西窗烛", "何", 1, true}, // 夜雨寄北的诗句以空格开头
+		{" 何当共剪西窗烛", "何", 2, false},
+		{" 何当共剪西窗烛", "烛", 7, true},
+		{"五花马、千金裘", "千金", 4, true},
+		{"床前明月光", "光", 6, false},
+		{"床前明月光", "床", 0, false},
+	}
+	for _, tt := range tests {
+		if got := keywordAt(tt.text, tt.key, tt.pos); got != tt.want {
+			t.Errorf("keywordAt(%q, %q, %d) = %v, want %v", tt.text, tt.key, tt.pos, got, tt.want)
+		}
+	}
+
+	poem := NewPoem(1, "夜雨寄北", "唐代", "李商隐", "君问归期未有期，巴山夜雨涨秋池。\n 何当共剪西窗烛，却话巴山夜雨时。")
+	for _, rule := range []string{"何@1", "烛@7"} {
+		if !poem.Matched(NewSearch(rule, false)) {
+			t.Errorf("%q does not match 夜雨寄北", rule)
+		}
+	}
+}
+
+func TestSearchTermMatched(t *testing.T) {
+	poem := NewPoem(12, "送元二使安西", "唐代", "王维", "渭城朝雨浥轻尘，\n客舍青青柳色新。\n劝君更尽一杯酒，\n西出阳关无故人。")
+	poem.Genre = "七绝"
+	poem.Tags = []string{"送别", "唐诗三百首"}
+	poem.Source = "唐詩三百首"
+	poem.Note = "又名渭城曲"
+	poem.Annotations = []*Annotation{{Word: "浥", Text: "湿润"}}
+
+	tests := []struct {
+		rule string
+		want bool
+	}{
+		{"title:元二", true},
+		{"t送元二", true},
+		{"author:王維", true},
+		{"dynasty:宋", false},
+		{"10-12", true},
+		{"13", false},
+		{"tag:送别", true},
+		{"tag:送", false},
+		{"genre:七", true},
+		{"note:渭城曲", true},
+		{"note:湿润", true},
+		{"note:阳关", false},
+		{"source:唐诗", true},
+		{"出处:课本", false},
+		{"阳关|玉关", true},
+		{"-阳关", false},
+		{"-a李白", true},
+		{"城@2", true},
+		{"城@1", false},
+		{`"更尽一杯"`, true},
+	}
+	for _, tt := range tests {
+		s, err := ParseSearch(tt.rule, false)
+		if err != nil {
+			t.Fatalf("ParseSearch(%q) error: %v", tt.rule, err)
+		}
+		if got := poem.Matched(s); got != tt.want {
+			t.Errorf("%q matched = %v, want %v", tt.rule, got, tt.want)
+		}
+	}
+}
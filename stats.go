@@ -0,0 +1,194 @@
+package main
+
+import (
+	"fmt"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/data/binding"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"strings"
+)
+
+// maxSuggestPoems 建议学习的诗最多列出几首
+const maxSuggestPoems = 10
+
+type StatsScreen struct {
+	root   fyne.CanvasObject
+	update func()
+}
+
+// positionsText 关键字在第几个字的分布，例如 1:3 2:5
+func positionsText(stat *KeywordStat) string {
+	parts := make([]string, 0, maxStatPos)
+	for i, n := range stat.Positions {
+		if n == 0 {
+			continue
+		}
+		if i == maxStatPos-1 {
+			parts = append(parts, fmt.Sprintf("%d+:%d", i+1, n))
+		} else {
+			parts = append(parts, fmt.Sprintf("%d:%d", i+1, n))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// suggestMarkdown 建议学习的诗，列出能多会的诗句
+func suggestMarkdown(keyword string, gains []*PoemGain) string {
+	if len(gains) == 0 {
+		return fmt.Sprintf("诗库中含“%s”的诗句都已经会了", keyword)
+	}
+
+	var b strings.Builder
+	for _, gain := range gains {
+		fmt.Fprintf(&b, "## %s\n\n多会 %d 句：\n\n", gain.Poem.Abstract(), len(gain.Lines))
+		for _, seg := range gain.Lines {
+			content := seg.Content
+			for _, key := range findVariants(content, keyword) {
+				content = highlight(content, key)
+			}
+			fmt.Fprintf(&b, "%s\n\n", content)
+		}
+	}
+	return display(b.String())
+}
+
+func NewStatsScreen(poems *Poems, mgr *ScreenManager, win fyne.Window) *StatsScreen {
+	keywordEntry := widget.NewEntry()
+	keywordEntry.SetPlaceHolder("关键字，用空格或逗号分开")
+	keywordEntry.SetText(strings.Join(FlowerKeywords, " "))
+	summary := widget.NewLabel("")
+	statData := binding.NewUntypedList()
+
+	// 统计的学习者，默认是当前学习者，可以选其他学习者而不切换
+	var profile *Profile
+	var memorised map[uint64]bool
+
+	updateList := func() {
+		var err error
+		if memorised, err = poems.MemorisedPoems(profile); err != nil {
+			dialog.ShowError(err, win)
+			return
+		}
+
+		stats := poems.KeywordStats(ParseKeywords(keywordEntry.Text), memorised)
+		list := make([]interface{}, len(stats))
+		weak := 0
+		for i := range list {
+			list[i] = stats[i]
+			if stats[i].Weak() {
+				weak++
+			}
+		}
+		_ = statData.Set(list)
+		summary.SetText(fmt.Sprintf("%s：%d 个关键字中有 %d 个较弱（已背的诗句少于 %d 句）", profile.Name, len(stats), weak, weakLines))
+	}
+
+	profileSelect := widget.NewSelect(nil, nil)
+	updateProfiles := func() {
+		profile = poems.Profile()
+		profiles, err := poems.Profiles()
+		if err != nil {
+			dialog.ShowError(err, win)
+			profiles = []*Profile{profile}
+		}
+
+		names := make([]string, len(profiles))
+		for i, pf := range profiles {
+			names[i] = pf.Name
+		}
+		profileSelect.OnChanged = nil
+		profileSelect.Options = names
+		profileSelect.SetSelected(profile.Name)
+		profileSelect.OnChanged = func(name string) {
+			for _, pf := range profiles {
+				if pf.Name == name {
+					profile = pf
+					break
+				}
+			}
+			updateList()
+		}
+	}
+	keywordEntry.OnSubmitted = func(string) {
+		updateList()
+	}
+
+	statList := widget.NewListWithData(statData,
+		func() fyne.CanvasObject {
+			keyword := widget.NewLabelWithStyle("", fyne.TextAlignCenter, fyne.TextStyle{Bold: true})
+			counts := widget.NewLabel("")
+			positions := widget.NewLabel("")
+			state := widget.NewLabel("")
+			return container.NewBorder(nil, nil, keyword, state, container.NewVBox(counts, positions))
+		},
+		func(item binding.DataItem, o fyne.CanvasObject) {
+			item.AddListener(binding.NewDataListener(func() {
+				i, _ := item.(binding.Untyped).Get()
+				stat := i.(*KeywordStat)
+
+				objs := o.(*fyne.Container).Objects
+				box, keyword, state := objs[0].(*fyne.Container), objs[1].(*widget.Label), objs[2].(*widget.Label)
+				counts, positions := box.Objects[0].(*widget.Label), box.Objects[1].(*widget.Label)
+
+				keyword.SetText(display(stat.Keyword))
+				counts.SetText(fmt.Sprintf("诗库 %d 句（不同的 %d 句），已背 %d 句，占 %.0f%%",
+					stat.Lines, stat.Distinct, stat.Memorised, stat.Coverage()*100))
+				positions.SetText(fmt.Sprintf("位置 %s", positionsText(stat)))
+				if stat.Weak() {
+					state.SetText("较弱")
+				} else {
+					state.SetText("")
+				}
+			}))
+		})
+	statList.OnSelected = func(id widget.ListItemID) {
+		statList.Unselect(id)
+
+		i, err := statData.GetValue(id)
+		if err != nil {
+			return
+		}
+		stat := i.(*KeywordStat)
+
+		text := widget.NewRichTextFromMarkdown(suggestMarkdown(stat.Keyword, poems.SuggestPoems(stat.Keyword, memorised, maxSuggestPoems)))
+		text.Wrapping = fyne.TextWrapWord
+		scroll := container.NewVScroll(text)
+		scroll.SetMinSize(fyne.NewSize(400, 400))
+		dialog.ShowCustom(fmt.Sprintf("建议学习的诗：%s", display(stat.Keyword)), "关闭", scroll, win)
+	}
+
+	statBtn := widget.NewButtonWithIcon("统计", theme.SearchIcon(), updateList)
+	resetBtn := widget.NewButtonWithIcon("常用", theme.ViewRefreshIcon(), func() {
+		keywordEntry.SetText(strings.Join(FlowerKeywords, " "))
+		updateList()
+	})
+	returnBtn := widget.NewButtonWithIcon("返回", theme.NavigateBackIcon(), func() {
+		mgr.SwitchTo("entry")
+	})
+
+	profileBar := container.NewBorder(nil, nil, widget.NewLabel("学习者"), nil, profileSelect)
+	top := container.NewVBox(profileBar, container.NewBorder(nil, nil, nil, container.NewHBox(resetBtn, statBtn), keywordEntry), summary)
+	root := container.NewBorder(top, returnBtn, nil, nil, statList)
+
+	update := func() {
+		updateProfiles()
+		updateList()
+	}
+	return &StatsScreen{root: root, update: update}
+}
+
+func (s *StatsScreen) Show(interface{}) {
+	s.update()
+	s.root.Show()
+}
+
+func (s *StatsScreen) Hide() {
+	s.root.Hide()
+}
+
+func (s *StatsScreen) RootObj() fyne.CanvasObject {
+	return s.root
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// TableColumn 表格中一列对应的字段
+type TableColumn int
+
+const (
+	ColumnIgnore TableColumn = iota
+	ColumnNo
+	ColumnTitle
+	ColumnDynasty
+	ColumnAuthor
+	ColumnContent
+	ColumnFavor
+)
+
+var TableColumnNames = []string{"忽略", "序号", "标题", "朝代", "作者", "内容", "收藏"}
+
+// tableColumns 导出的列，也是没有表头时默认的列
+var tableColumns = []TableColumn{ColumnNo, ColumnTitle, ColumnDynasty, ColumnAuthor, ColumnContent, ColumnFavor}
+
+const utf8BOM = "\ufeff"
+
+// TableOptions 表格的列和内容单元格中的分行符号，LineSeparator为空时单元格内直接换行
+type TableOptions struct {
+	Comma         rune
+	Header        bool
+	LineSeparator string
+	Columns       []TableColumn
+}
+
+// Table 读取的表格，Lines是每个单元格在文件中开始的行号，
+// 内容单元格中可以换行，所以第几条记录不一定是第几行
+type Table struct {
+	Rows  [][]string
+	Lines [][]int
+}
+
+// line 第i条记录第j个单元格所在的行，j超出范围时为记录开始的行
+func (t *Table) line(i, j int) int {
+	lines := t.Lines[i]
+	switch {
+	case j < len(lines):
+		return lines[j]
+	case len(lines) != 0:
+		return lines[0]
+	default:
+		return 0
+	}
+}
+
+// ReadTable 读取CSV或TSV的所有行，去掉Excel加上的BOM
+func ReadTable(reader io.Reader, comma rune) (*Table, error) {
+	r := bufio.NewReader(reader)
+	if bom, err := r.Peek(len(utf8BOM)); err == nil && string(bom) == utf8BOM {
+		_, _ = r.Discard(len(utf8BOM))
+	}
+
+	cr := csv.NewReader(r)
+	cr.Comma = comma
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = true
+
+	table := &Table{Rows: make([][]string, 0), Lines: make([][]int, 0)}
+	for {
+		row, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				return nil, &ImportError{Line: parseErr.Line, Err: parseErr.Err}
+			}
+			return nil, err
+		}
+
+		lines := make([]int, len(row))
+		for j := range row {
+			lines[j], _ = cr.FieldPos(j)
+		}
+		table.Rows = append(table.Rows, row)
+		table.Lines = append(table.Lines, lines)
+	}
+	if len(table.Rows) == 0 {
+		return nil, errors.New("表格是空的")
+	}
+	return table, nil
+}
+
+// GuessColumns 按表头猜每一列对应的字段，没有认识的表头时认为没有表头，按导出的顺序
+func GuessColumns(header []string) ([]TableColumn, bool) {
+	aliases := map[string]TableColumn{
+		"序号": ColumnNo, "编号": ColumnNo, "id": ColumnNo, "no": ColumnNo,
+		"标题": ColumnTitle, "题目": ColumnTitle, "诗名": ColumnTitle, "title": ColumnTitle,
+		"朝代": ColumnDynasty, "dynasty": ColumnDynasty,
+		"作者": ColumnAuthor, "诗人": ColumnAuthor, "author": ColumnAuthor,
+		"内容": ColumnContent, "正文": ColumnContent, "诗句": ColumnContent, "content": ColumnContent,
+		"收藏": ColumnFavor, "favor": ColumnFavor,
+	}
+
+	columns := make([]TableColumn, len(header))
+	found := false
+	for i, name := range header {
+		if column, ok := aliases[strings.ToLower(strings.TrimSpace(name))]; ok {
+			columns[i] = column
+			found = true
+		}
+	}
+	if found {
+		return columns, true
+	}
+
+	for i := range columns {
+		if i < len(tableColumns) {
+			columns[i] = tableColumns[i]
+		}
+	}
+	return columns, false
+}
+
+func parseFavor(s string) bool {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "1", "true", "yes", "y", "是", "√", "✓", "★":
+		return true
+	}
+	return false
+}
+
+// ParseTable 按列的设置把表格的每一行转换成诗，出错时报告文件中的行号
+func ParseTable(table *Table, opts *TableOptions) ([]*Poem, error) {
+	rows := table.Rows
+	hasTitle, hasContent := false, false
+	for _, column := range opts.Columns {
+		hasTitle = hasTitle || column == ColumnTitle
+		hasContent = hasContent || column == ColumnContent
+	}
+	if !hasTitle || !hasContent {
+		return nil, errors.New("必须指定标题和内容所在的列")
+	}
+
+	start := 0
+	if opts.Header {
+		start = 1
+	}
+
+	list := make([]*Poem, 0, len(rows)-start)
+	for i := start; i < len(rows); i++ {
+		poem := &Poem{}
+		for j, cell := range rows[i] {
+			if j >= len(opts.Columns) {
+				break
+			}
+
+			cell = strings.TrimSpace(cell)
+			switch opts.Columns[j] {
+			case ColumnNo:
+				if len(cell) == 0 {
+					continue
+				}
+				no, err := strconv.ParseUint(cell, 10, 64)
+				if err != nil {
+					return nil, &ImportError{Index: len(list) + 1, Line: table.line(i, j), Field: "序号", Err: fmt.Errorf("%s不是正整数", cell)}
+				}
+				poem.No = no
+			case ColumnTitle:
+				poem.Title = cell
+			case ColumnDynasty:
+				poem.Dynasty = cell
+			case ColumnAuthor:
+				poem.Author = cell
+			case ColumnContent:
+				if len(opts.LineSeparator) != 0 {
+					cell = strings.ReplaceAll(cell, opts.LineSeparator, "\n")
+				}
+				poem.Content = breakLines(cell)
+			case ColumnFavor:
+				poem.Favor = parseFavor(cell)
+			}
+		}
+
+		// 跳过空行
+		if len(poem.Title) == 0 && len(poem.Content) == 0 {
+			continue
+		}
+		if len(poem.Title) == 0 {
+			return nil, &ImportError{Index: len(list) + 1, Line: table.line(i, 0), Field: "标题", Err: errors.New("标题为空")}
+		}
+		if len(poem.Content) == 0 {
+			return nil, &ImportError{Index: len(list) + 1, Line: table.line(i, 0), Field: "内容", Err: errors.New("内容为空")}
+		}
+
+		poem.MakeSegments()
+		list = append(list, poem)
+	}
+
+	if len(list) == 0 {
+		return nil, errors.New("表格中没有诗")
+	}
+	return list, nil
+}
+
+// WriteTable 导出成带表头的表格，加上BOM方便Excel识别UTF-8
+func WriteTable(writer io.Writer, list []*Poem, comma rune, lineSeparator string) error {
+	if _, err := io.WriteString(writer, utf8BOM); err != nil {
+		return err
+	}
+
+	cw := csv.NewWriter(writer)
+	cw.Comma = comma
+
+	header := make([]string, len(tableColumns))
+	for i, column := range tableColumns {
+		header[i] = TableColumnNames[column]
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+
+	for _, poem := range list {
+		content := poem.Content
+		if len(lineSeparator) != 0 {
+			content = strings.ReplaceAll(content, "\n", lineSeparator)
+		}
+		favor := ""
+		if poem.Favor {
+			favor = "是"
+		}
+
+		row := []string{strconv.FormatUint(poem.No, 10), poem.Title, poem.Dynasty, poem.Author, content, favor}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGuessColumns(t *testing.T) {
+	tests := []struct {
+		header []string
+		want   []TableColumn
+		found  bool
+	}{
+		{[]string{"序号", "标题", "朝代", "作者", "内容", "收藏"}, tableColumns, true},
+		{[]string{"Title", " Author ", "备注", "正文"}, []TableColumn{ColumnTitle, ColumnAuthor, ColumnIgnore, ColumnContent}, true},
+		{[]string{"1", "静夜思", "唐"}, []TableColumn{ColumnNo, ColumnTitle, ColumnDynasty}, false},
+	}
+	for _, tt := range tests {
+		got, found := GuessColumns(tt.header)
+		if found != tt.found || len(got) != len(tt.want) {
+			t.Errorf("GuessColumns(%q) = %v, %v, want %v, %v", tt.header, got, found, tt.want, tt.found)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("GuessColumns(%q) = %v, want %v", tt.header, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestParseTable(t *testing.T) {
+	const header = "序号,标题,朝代,作者,内容,收藏\n"
+	opts := func(separator string) *TableOptions {
+		return &TableOptions{Comma: ',', Header: true, LineSeparator: separator, Columns: tableColumns}
+	}
+
+	tests := []struct {
+		name    string
+		data    string
+		opts    *TableOptions
+		err     string
+		titles  []string
+		content string // 第一首诗的内容
+	}{
+		{
+			name:    "bom and multi-line cell",
+			data:    utf8BOM + header + "1,静夜思,唐,李白,\"床前明月光，\n疑是地上霜。\",是\n2,春晓,唐,孟浩然,春眠不觉晓，处处闻啼鸟。,\n",
+			opts:    opts(""),
+			titles:  []string{"静夜思", "春晓"},
+			content: "床前明月光，\n疑是地上霜。",
+		},
+		{
+			name:    "line separator",
+			data:    header + "1,静夜思,唐,李白,床前明月光，/疑是地上霜。,\n",
+			opts:    opts("/"),
+			titles:  []string{"静夜思"},
+			content: "床前明月光，\n疑是地上霜。",
+		},
+		{
+			name:   "blank rows skipped",
+			data:   header + ",,,,,\n1,静夜思,唐,李白,床前明月光，疑是地上霜。,\n",
+			opts:   opts(""),
+			titles: []string{"静夜思"},
+		},
+		{
+			name: "bad number after multi-line cells",
+			data: header + "1,静夜思,唐,李白,\"床前明月光，\n疑是地上霜。\n举头望明月，\n低头思故乡。\",\nx,春晓,唐,孟浩然,春眠不觉晓，,\n",
+			opts: opts(""),
+			err:  "第2首诗（第6行）序号字段：x不是正整数",
+		},
+		{
+			name: "empty content",
+			data: header + "1,静夜思,唐,李白,\"床前明月光，\n疑是地上霜。\",\n2,春晓,唐,孟浩然,,\n",
+			opts: opts(""),
+			err:  "第2首诗（第4行）内容字段：内容为空",
+		},
+		{
+			name: "missing title column",
+			data: header + "1,静夜思,唐,李白,床前明月光，,\n",
+			opts: &TableOptions{Comma: ',', Header: true, Columns: []TableColumn{ColumnNo, ColumnIgnore, ColumnDynasty, ColumnAuthor, ColumnContent}},
+			err:  "必须指定标题和内容所在的列",
+		},
+	}
+	for _, tt := range tests {
+		table, err := ReadTable(strings.NewReader(tt.data), tt.opts.Comma)
+		if err != nil {
+			t.Errorf("%s: ReadTable: %v", tt.name, err)
+			continue
+		}
+		list, err := ParseTable(table, tt.opts)
+		if len(tt.err) != 0 {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("%s: error %v, want %s", tt.name, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if len(list) != len(tt.titles) {
+			t.Errorf("%s: %d poems, want %d", tt.name, len(list), len(tt.titles))
+			continue
+		}
+		for i, poem := range list {
+			if poem.Title != tt.titles[i] {
+				t.Errorf("%s: poem %d title %s, want %s", tt.name, i+1, poem.Title, tt.titles[i])
+			}
+		}
+		if len(tt.content) != 0 && list[0].Content != tt.content {
+			t.Errorf("%s: content %q, want %q", tt.name, list[0].Content, tt.content)
+		}
+	}
+}
+
+func TestReadTableEmpty(t *testing.T) {
+	if _, err := ReadTable(strings.NewReader(""), ','); err == nil {
+		t.Error("ReadTable accepted an empty table")
+	}
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jung-kurt/gofpdf"
+	"io"
+	"strings"
+)
+
+// WorksheetLayout 打印的版式
+type WorksheetLayout int
+
+const (
+	WorksheetText      WorksheetLayout = iota // 全文
+	WorksheetPinyin                           // 全文加拼音
+	WorksheetDictation                        // 田字格默写
+	WorksheetFlower                           // 飞花令练习，列出含关键字的所有诗句
+)
+
+var WorksheetLayoutNames = []string{"全文", "带拼音", "默写（田字格）", "飞花令练习"}
+
+const (
+	pdfFont   = "noto"
+	pdfMargin = 15.0
+	gridSize  = 12.0 // 田字格的边长，毫米
+	rubyWidth = 10.0 // 带拼音时每个字的宽度
+)
+
+type worksheet struct {
+	pdf           *gofpdf.Fpdf
+	width, height float64
+}
+
+func newWorksheet(title string) *worksheet {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetTitle(title, true)
+	pdf.SetMargins(pdfMargin, pdfMargin, pdfMargin)
+	pdf.SetAutoPageBreak(true, pdfMargin)
+	// 和界面用同一套字体，离线也能显示中文
+	pdf.AddUTF8FontFromBytes(pdfFont, "", notoScContent)
+	pdf.AddUTF8FontFromBytes(pdfFont, "B", notoScBoldContent)
+	pdf.AddPage()
+
+	width, height := pdf.GetPageSize()
+	return &worksheet{pdf: pdf, width: width - 2*pdfMargin, height: height}
+}
+
+// ensure 剩余的高度不够h时换页
+func (w *worksheet) ensure(h float64) {
+	if w.pdf.GetY()+h > w.height-pdfMargin {
+		w.pdf.AddPage()
+	}
+}
+
+func (w *worksheet) heading(poem *Poem) {
+	w.ensure(24)
+	w.pdf.SetFont(pdfFont, "B", 16)
+	w.pdf.CellFormat(w.width, 9, display(poem.Title), "", 1, "C", false, 0, "")
+	w.pdf.SetFont(pdfFont, "", 11)
+	w.pdf.CellFormat(w.width, 7, display(fmt.Sprintf("%s %s", poem.Dynasty, poem.Author)), "", 1, "C", false, 0, "")
+	w.pdf.Ln(2)
+}
+
+func (w *worksheet) text(poem *Poem) {
+	w.heading(poem)
+	w.pdf.SetFont(pdfFont, "", 14)
+	for _, line := range strings.Split(poem.Content, "\n") {
+		w.ensure(8)
+		w.pdf.CellFormat(w.width, 8, display(strings.TrimSpace(line)), "", 1, "C", false, 0, "")
+	}
+	w.pdf.Ln(6)
+}
+
+// rubyLine 拼音写在字的上方，一行放不下时换行
+func (w *worksheet) rubyLine(line string) {
+	runes, py := []rune(display(line)), Pinyin(normalise(line))
+	perRow := int(w.width / rubyWidth)
+	for start := 0; start < len(runes); start += perRow {
+		end := start + perRow
+		if end > len(runes) {
+			end = len(runes)
+		}
+
+		w.ensure(14)
+		x := pdfMargin + (w.width-float64(end-start)*rubyWidth)/2
+		y := w.pdf.GetY()
+		for i := start; i < end; i++ {
+			w.pdf.SetXY(x+float64(i-start)*rubyWidth, y)
+			w.pdf.SetFont(pdfFont, "", 8)
+			w.pdf.CellFormat(rubyWidth, 5, py[i], "", 2, "C", false, 0, "")
+			w.pdf.SetFont(pdfFont, "", 14)
+			w.pdf.CellFormat(rubyWidth, 8, string(runes[i]), "", 0, "C", false, 0, "")
+		}
+		w.pdf.SetXY(pdfMargin, y+14)
+	}
+}
+
+func (w *worksheet) pinyin(poem *Poem) {
+	w.heading(poem)
+	for _, line := range strings.Split(poem.Content, "\n") {
+		w.rubyLine(strings.TrimSpace(line))
+	}
+	w.pdf.Ln(6)
+}
+
+// grid 画一个田字格，中间的十字用虚线
+func (w *worksheet) grid(x, y float64) {
+	w.pdf.SetDrawColor(0, 0, 0)
+	w.pdf.SetLineWidth(0.3)
+	w.pdf.Rect(x, y, gridSize, gridSize, "D")
+
+	w.pdf.SetDrawColor(160, 160, 160)
+	w.pdf.SetLineWidth(0.1)
+	w.pdf.SetDashPattern([]float64{1, 1}, 0)
+	w.pdf.Line(x, y+gridSize/2, x+gridSize, y+gridSize/2)
+	w.pdf.Line(x+gridSize/2, y, x+gridSize/2, y+gridSize)
+	w.pdf.SetDashPattern([]float64{}, 0)
+}
+
+// dictation 每句一行田字格，句末的标点留着作提示
+func (w *worksheet) dictation(poem *Poem) {
+	w.heading(poem)
+	perRow := int(w.width/gridSize) - 1
+	for _, seg := range poem.Segments {
+		n := len([]rune(stripPunctuation(seg.Text()))) // 句首的空白和句中的标点不占格子
+		punctuation := strings.TrimPrefix(seg.Content, seg.Text())
+		for start := 0; start < n; start += perRow {
+			count := perRow
+			if start+count > n {
+				count = n - start
+			}
+
+			w.ensure(gridSize + 3)
+			y := w.pdf.GetY()
+			for i := 0; i < count; i++ {
+				w.grid(pdfMargin+float64(i)*gridSize, y)
+			}
+			if start+count == n {
+				w.pdf.SetXY(pdfMargin+float64(count)*gridSize, y)
+				w.pdf.SetFont(pdfFont, "", 14)
+				w.pdf.CellFormat(gridSize, gridSize, punctuation, "", 0, "L", false, 0, "")
+			}
+			w.pdf.SetXY(pdfMargin, y+gridSize+3)
+		}
+	}
+	w.pdf.Ln(6)
+}
+
+// keywordText 写一句诗，关键字加粗
+func (w *worksheet) keywordText(text, keyword string, size float64) {
+	runes, normalised, key := []rune(text), []rune(normalise(text)), []rune(normalise(keyword))
+	start := 0
+	for i := 0; i+len(key) <= len(runes); {
+		if string(normalised[i:i+len(key)]) != string(key) {
+			i++
+			continue
+		}
+
+		w.pdf.SetFont(pdfFont, "", size)
+		w.pdf.Write(8, string(runes[start:i]))
+		w.pdf.SetFont(pdfFont, "B", size)
+		w.pdf.Write(8, string(runes[i:i+len(key)]))
+		i += len(key)
+		start = i
+	}
+	w.pdf.SetFont(pdfFont, "", size)
+	w.pdf.Write(8, string(runes[start:]))
+}
+
+// flower 列出含关键字的诗句，关键字加粗
+func (w *worksheet) flower(list []*Poem, keyword string) {
+	w.pdf.SetFont(pdfFont, "B", 18)
+	w.pdf.CellFormat(w.width, 12, fmt.Sprintf("飞花令 · %s", keyword), "", 1, "C", false, 0, "")
+	w.pdf.Ln(4)
+
+	n := 0
+	for _, poem := range list {
+		for _, seg := range poem.Segments {
+			text := seg.Text()
+			if !strings.Contains(normalise(text), normalise(keyword)) {
+				continue
+			}
+			n++
+
+			w.ensure(8)
+			w.pdf.SetFont(pdfFont, "", 13)
+			w.pdf.Write(8, fmt.Sprintf("%d. ", n))
+			w.keywordText(display(text), keyword, 13)
+			w.pdf.SetFont(pdfFont, "", 10)
+			w.pdf.Write(8, display(fmt.Sprintf("  —— %s《%s》", poem.Author, poem.Title)))
+			w.pdf.Ln(8)
+		}
+	}
+
+	if n == 0 {
+		w.pdf.SetFont(pdfFont, "", 13)
+		w.pdf.CellFormat(w.width, 8, "没有含这个字的诗句", "", 1, "C", false, 0, "")
+	}
+}
+
+// WriteWorksheet 把选中的诗按版式输出成PDF，飞花令练习需要关键字
+func WriteWorksheet(writer io.Writer, list []*Poem, layout WorksheetLayout, keyword string) error {
+	if len(list) == 0 {
+		return errors.New("没有要打印的诗")
+	}
+	keyword = strings.TrimSpace(keyword)
+	if layout == WorksheetFlower && len(keyword) == 0 {
+		return errors.New("请输入飞花令的关键字")
+	}
+
+	w := newWorksheet(WorksheetLayoutNames[layout])
+	if layout == WorksheetFlower {
+		w.flower(list, keyword)
+	} else {
+		for _, poem := range list {
+			switch layout {
+			case WorksheetPinyin:
+				w.pinyin(poem)
+			case WorksheetDictation:
+				w.dictation(poem)
+			default:
+				w.text(poem)
+			}
+		}
+	}
+
+	return w.pdf.Output(writer)
+}